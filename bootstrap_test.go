@@ -0,0 +1,136 @@
+package xicor
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBootstrap(t *testing.T) {
+	percentile, err := Compute(xx, yy, WithBootstrap(IntervalPercentile, 500), WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lo, hi := percentile.ConfidenceInterval[0], percentile.ConfidenceInterval[1]
+	if !(lo < percentile.Xi && percentile.Xi < hi) {
+		t.Errorf("percentile interval doesn't contain xi; got: %v, xi: %v", percentile.ConfidenceInterval, percentile.Xi)
+	}
+	// The width should be close to that of a normal interval with the asymptotic standard deviation
+	if w := (hi - lo) / (2 * 1.96 * percentile.SD); w < 0.7 || w > 1.3 {
+		t.Errorf("unexpected width of the percentile interval: %v, relative to the asymptotic one: %v", hi-lo, w)
+	}
+
+	// The basic interval reflects the same replicates around xi
+	basic, err := Compute(xx, yy, WithBootstrap(IntervalBasic, 500), WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEpsilon(t, basic.ConfidenceInterval[0], 2*basic.Xi-hi)
+	assertEpsilon(t, basic.ConfidenceInterval[1], 2*basic.Xi-lo)
+
+	bca, err := Compute(xx, yy, WithBootstrap(IntervalBCa, 500), WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(bca.ConfidenceInterval[0] < bca.Xi && bca.Xi < bca.ConfidenceInterval[1]) {
+		t.Errorf("BCa interval doesn't contain xi; got: %v, xi: %v", bca.ConfidenceInterval, bca.Xi)
+	}
+
+	// A lower confidence level gives a narrower interval
+	narrow, _ := Compute(xx, yy, WithBootstrap(IntervalPercentile, 500), WithConfidenceLevel(0.5), WithSeed(3))
+	if !(lo < narrow.ConfidenceInterval[0] && narrow.ConfidenceInterval[1] < hi) {
+		t.Errorf("expected a narrower interval at a lower level; got: %v, at 0.95: %v", narrow.ConfidenceInterval, percentile.ConfidenceInterval)
+	}
+
+	// The symmetric coefficient is bootstrapped as a whole
+	sym, err := Compute(xx, yy, WithSymmetric(), WithBootstrap(IntervalPercentile, 200), WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(sym.ConfidenceInterval[0] < sym.Xi && sym.Xi < sym.ConfidenceInterval[1]) {
+		t.Errorf("interval of the symmetric coefficient doesn't contain it; got: %v, xi: %v", sym.ConfidenceInterval, sym.Xi)
+	}
+
+	// The interval isn't calculated unless it's requested
+	res, _ := Compute(xx, yy)
+	if res.ConfidenceInterval != [2]float64{} {
+		t.Errorf("expected no confidence interval, got: %v", res.ConfidenceInterval)
+	}
+}
+
+func TestBootstrapWorkers(t *testing.T) {
+	x, y := xx[:300], yy[:300]
+
+	var want Result
+	for _, workers := range []int{1, 3, 8} {
+		got, err := Compute(x, y, WithBootstrap(IntervalBCa, 300), WithSeed(9), WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if workers == 1 {
+			want = got
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("bootstrap depends on the number of workers; got: %+v, want: %+v", got, want)
+		}
+	}
+
+	// Bootstrapping doesn't change the draws of the permutation test
+	boot, _ := Compute(x, y, WithBootstrap(IntervalPercentile, 100), WithPermutationPvalue(200), WithSeed(9))
+	perm, _ := Compute(x, y, WithPermutationPvalue(200), WithSeed(9))
+	if boot.Pvalue != perm.Pvalue || boot.SD != perm.SD {
+		t.Errorf("bootstrap changes the permutation test; got: %v, want: %v", boot.Pvalue, perm.Pvalue)
+	}
+}
+
+func TestBootstrapErrors(t *testing.T) {
+	tcs := map[string]struct {
+		options []func(*Xi)
+		err     string
+	}{
+		"method": {[]func(*Xi){WithBootstrap("normal", 100)}, "xicor: invalid confidence interval method; use either 'percentile', 'basic', 'bca' or 'wald'"},
+		"nboot":  {[]func(*Xi){WithBootstrap(IntervalBasic, 0)}, "xicor: the bootstrap needs at least one resample"},
+		"level":  {[]func(*Xi){WithBootstrap(IntervalBasic, 100), WithConfidenceLevel(1)}, "xicor: the confidence level must be between 0 and 1"},
+		"nan":    {[]func(*Xi){WithBootstrap(IntervalBasic, 100), WithConfidenceLevel(math.NaN())}, "xicor: the confidence level must be between 0 and 1"},
+	}
+	for name, tc := range tcs {
+		_, err := Compute(xx, yy, tc.options...)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: didn't receive the correct error: %v", name, err)
+		}
+	}
+
+	// Too few pairs leave the half-samples without neighbours, for any interval
+	for _, interval := range []string{IntervalPercentile, IntervalBasic, IntervalBCa} {
+		for _, n := range []int{2, 3} {
+			_, err := Compute(xx[:n], yy[:n], WithBootstrap(interval, 100))
+			if err == nil || err.Error() != "xicor: the bootstrap needs at least 4 pairs, so that every half of them has neighbours" {
+				t.Errorf("%s with %v pairs: didn't receive the correct error: %v", interval, n, err)
+			}
+		}
+		res, err := Compute(xx[:4], yy[:4], WithBootstrap(interval, 100), WithSeed(1))
+		if err != nil || math.IsNaN(res.ConfidenceInterval[0]) || math.IsNaN(res.ConfidenceInterval[1]) {
+			t.Errorf("%s with 4 pairs: expected an interval, got: %v, error: %v", interval, res.ConfidenceInterval, err)
+		}
+	}
+
+	// The Wald interval would come out with no width at all
+	for _, n := range []int{2, 3} {
+		_, err := Compute(xx[:n], yy[:n], WithWaldInterval())
+		if err == nil || err.Error() != "xicor: the Wald interval needs at least 4 pairs" {
+			t.Errorf("wald with %v pairs: didn't receive the correct error: %v", n, err)
+		}
+	}
+	res, err := Compute(xx[:4], yy[:4], WithWaldInterval(), WithSeed(1))
+	if err != nil || math.IsNaN(res.StdErr) {
+		t.Errorf("wald with 4 pairs: expected an interval, got: %v, error: %v", res.ConfidenceInterval, err)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	a := []float64{1, 2, 4, 8}
+	for p, want := range map[float64]float64{0: 1, 1: 8, 0.5: 3, 1. / 3.: 2, 0.9: 6.8, -1: 1, 2: 8} {
+		assertEpsilon(t, quantile(a, p), want)
+	}
+}
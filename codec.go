@@ -0,0 +1,287 @@
+package xicor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// nnBlock is the number of observations whose nearest neighbours are searched with a single random stream.
+const nnBlock = 256
+
+// Codec calculates the conditional dependence coefficient T(Y, Z | X) of Azadkia and Chatterjee (arxiv.org/abs/1910.12327), configured by the same functional options as `New`.
+// It measures how much Y depends on Z beyond what is already explained by X, ranging from 0 when Y is conditionally independent of Z given X, to 1 when Y is a function of Z and X.
+// When x has no columns, it is the unconditional coefficient T(Y, Z).
+//
+// The predictors z and x are sets of columns, each as long as y, or sets of rows when `WithRowMajor` is used. Pairs are dropped, rejected or kept according to the missing-value policy, except that NaNs are only
+// treated as the largest value in y. Like R's `FOCI::codec`, the columns are standardized unless `WithoutStandardization` is used.
+// The nearest neighbours are found by brute force in O(n²) time, with ties broken at random.
+func Codec(y []float64, z, x [][]float64, options ...func(*Xi)) (float64, error) {
+	return CodecContext(context.Background(), y, z, x, options...)
+}
+
+// CodecContext is like `Codec`, but stops early and returns the context's error once `ctx` is done.
+func CodecContext(ctx context.Context, y []float64, z, x [][]float64, options ...func(*Xi)) (float64, error) {
+	d := New(nil, nil, options...)
+	z, err := d.columns(z)
+	if err != nil {
+		return 0, err
+	}
+	x, err = d.columns(x)
+	if err != nil {
+		return 0, err
+	}
+	if len(z) == 0 {
+		return 0, errors.New("xicor: no columns given for z")
+	}
+	y, sets, err := cleanColumns(d, y, z, x)
+	if err != nil {
+		return 0, err
+	}
+	z, x = sets[0], sets[1]
+	if d.Standardize {
+		z, x = standardize(z), standardize(x)
+	}
+
+	c := newCodec(d, y)
+	if len(x) == 0 {
+		qz, err := c.q(ctx, z, 0)
+		if err != nil {
+			return 0, err
+		}
+		return qz / c.s, nil
+	}
+
+	qx, err := c.q(ctx, x, 0)
+	if err != nil {
+		return 0, err
+	}
+	qxz, err := c.q(ctx, append(append([][]float64{}, x...), z...), 1)
+	if err != nil {
+		return 0, err
+	}
+	return c.conditional(qx, qxz), nil
+}
+
+// codec holds the ranks of the response shared by all the nearest-neighbour statistics of a calculation.
+type codec struct {
+	d *Xi
+	n int
+	// r[i] is the number of j s.t. y[j] <= y[i], and l[i] the number of j s.t. y[j] >= y[i].
+	r, l []float64
+	// s is the normalizing constant of the unconditional coefficient, and sc of the conditional one.
+	s, sc float64
+	seed  int64
+}
+
+func newCodec(d *Xi, y []float64) *codec {
+	c := &codec{d: d, n: len(y), seed: d.streamSeed(d.rng())}
+	c.r, c.l, _ = rankMaxes(y)
+
+	n := float64(c.n)
+	for i := range c.r {
+		c.s += c.l[i] * (n - c.l[i]) / (n * n * n)
+		c.sc += (c.r[i] - c.l[i]*c.l[i]/n) / (n * n)
+	}
+	return c
+}
+
+// q calculates the statistic Q(Y, X) = Σ min(R_i, R_N(i)) - L_i²/n, scaled by 1/n², where N(i) is the nearest neighbour of observation i among the columns.
+// The neighbours of different statistics of the same calculation are drawn from independent streams, identified by `stream`.
+func (c *codec) q(ctx context.Context, columns [][]float64, stream int) (float64, error) {
+	nn, err := c.d.nearest(ctx, columns, chunkSeed(c.seed, stream))
+	if err != nil {
+		return 0, err
+	}
+
+	n := float64(c.n)
+	var q float64
+	for i, j := range nn {
+		q += math.Min(c.r[i], c.r[j]) - c.l[i]*c.l[i]/n
+	}
+	return q / (n * n), nil
+}
+
+// conditional returns T(Y, Z | X) from Q(Y, X) and Q(Y, (X, Z)).
+func (c *codec) conditional(qx, qxz float64) float64 {
+	return (qxz - qx) / (c.sc - qx)
+}
+
+// nearest returns the index of the nearest neighbour of each observation, according to the distance between the rows of `columns`; by default the Euclidean distance.
+// Ties are broken uniformly at random; the observations are split in blocks which are searched in parallel, each with its own stream derived from `seed`.
+func (d *Xi) nearest(ctx context.Context, columns [][]float64, seed int64) ([]int, error) {
+	n := len(columns[0])
+	p := len(columns)
+
+	// Lay the points out row by row
+	points := make([]float64, n*p)
+	for k, col := range columns {
+		for i, val := range col {
+			points[i*p+k] = val
+		}
+	}
+
+	dist := d.Distance
+	if dist == nil {
+		// The squared distance finds the same neighbours as the Euclidean distance
+		dist = sqDist
+	}
+
+	nn := make([]int, n)
+	nblocks := (n + nnBlock - 1) / nnBlock
+	err := forEach(ctx, d.Workers, nblocks, func(_, b int) {
+		r := rand.New(rand.NewSource(chunkSeed(seed, b)))
+		end := (b + 1) * nnBlock
+		if end > n {
+			end = n
+		}
+		for i := b * nnBlock; i < end; i++ {
+			pi := points[i*p : (i+1)*p]
+			best, ties := math.Inf(1), 0
+			for j := 0; j < n; j++ {
+				if j == i {
+					continue
+				}
+				dj := dist(pi, points[j*p:(j+1)*p])
+				switch {
+				case dj < best:
+					best, ties = dj, 1
+					nn[i] = j
+				case dj == best:
+					// Reservoir sampling keeps each of the tied neighbours with equal probability
+					ties++
+					if r.Intn(ties) == 0 {
+						nn[i] = j
+					}
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nn, nil
+}
+
+func sqDist(a, b []float64) float64 {
+	var res float64
+	for k := range a {
+		res += (a[k] - b[k]) * (a[k] - b[k])
+	}
+	return res
+}
+
+// standardize returns copies of the columns scaled to zero mean and unit variance; constant columns are only centered.
+func standardize(columns [][]float64) [][]float64 {
+	res := make([][]float64, len(columns))
+	for k, col := range columns {
+		m := mean(col)
+		s := sd(col)
+		if s == 0 || math.IsNaN(s) {
+			s = 1
+		}
+		res[k] = make([]float64, len(col))
+		for i, val := range col {
+			res[k][i] = (val - m) / s
+		}
+	}
+	return res
+}
+
+// columns returns the multivariate predictor `x` as a set of columns, transposing it when the configuration says it's given row by row.
+func (d *Xi) columns(x [][]float64) ([][]float64, error) {
+	if !d.RowMajor || len(x) == 0 {
+		return x, nil
+	}
+
+	p := len(x[0])
+	res := make([][]float64, p)
+	for k := range res {
+		res[k] = make([]float64, len(x))
+	}
+	for i, row := range x {
+		if len(row) != p {
+			return nil, errors.New("xicor: rows of the multivariate predictor have different lengths")
+		}
+		for k, val := range row {
+			res[k][i] = val
+		}
+	}
+	return res, nil
+}
+
+// cleanColumns validates the sets of columns against y and applies the missing-value policy to whole observations.
+// NaNs in the columns can't be ordered, so they're rejected by the `MissingLast` policy.
+func cleanColumns(d *Xi, y []float64, sets ...[][]float64) ([]float64, [][][]float64, error) {
+	for _, set := range sets {
+		for _, col := range set {
+			if len(col) != len(y) {
+				return nil, nil, errors.New("xicor: mismatched size of input vectors")
+			}
+		}
+	}
+	if len(y) < 2 {
+		return nil, nil, errors.New("xicor: at least two observations are needed")
+	}
+
+	missing := func(i int) bool {
+		for _, set := range sets {
+			for _, col := range set {
+				if math.IsNaN(col[i]) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch d.Missing {
+	case "", MissingDrop:
+	case MissingError:
+		for i := range y {
+			if math.IsNaN(y[i]) || missing(i) {
+				return nil, nil, errors.New("xicor: input vectors contain NaN values")
+			}
+		}
+		return y, sets, nil
+	case MissingLast:
+		for i := range y {
+			if missing(i) {
+				return nil, nil, errors.New("xicor: NaNs can only be treated as the largest value in y")
+			}
+		}
+		return y, sets, nil
+	default:
+		return nil, nil, errors.New("xicor: invalid missing-value policy; use either 'drop', 'error' or 'last'")
+	}
+
+	var keep []int
+	for i := range y {
+		if !math.IsNaN(y[i]) && !missing(i) {
+			keep = append(keep, i)
+		}
+	}
+	if len(keep) == len(y) {
+		return y, sets, nil
+	}
+	if len(keep) < 2 {
+		return nil, nil, errors.New("xicor: at least two observations are needed")
+	}
+
+	newY := make([]float64, len(keep))
+	for k, i := range keep {
+		newY[k] = y[i]
+	}
+	newSets := make([][][]float64, len(sets))
+	for s, set := range sets {
+		newSets[s] = make([][]float64, len(set))
+		for c, col := range set {
+			newSets[s][c] = make([]float64, len(keep))
+			for k, i := range keep {
+				newSets[s][c][k] = col[i]
+			}
+		}
+	}
+	return newY, newSets, nil
+}
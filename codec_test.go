@@ -0,0 +1,127 @@
+package xicor
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func codecData(n int) (x1, x2, z, y []float64) {
+	r := rand.New(rand.NewSource(12))
+	x1 = make([]float64, n)
+	x2 = make([]float64, n)
+	z = make([]float64, n)
+	y = make([]float64, n)
+	for i := 0; i < n; i++ {
+		x1[i] = r.Float64()
+		x2[i] = r.Float64()
+		z[i] = r.Float64()
+		y[i] = math.Sin(4*x1[i]) + x2[i]*x2[i] + 0.1*r.NormFloat64()
+	}
+	return x1, x2, z, y
+}
+
+func TestCodec(t *testing.T) {
+	x1, x2, z, y := codecData(1000)
+
+	// Y is a function of (X1, X2), and independent of Z
+	got, err := Codec(y, [][]float64{x1, x2}, nil, WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got < 0.7 {
+		t.Errorf("expected a strong dependence of y on (x1, x2), got: %v", got)
+	}
+
+	got, _ = Codec(y, [][]float64{z}, nil, WithSeed(1))
+	if abs(got) > 0.1 {
+		t.Errorf("expected no dependence of y on z, got: %v", got)
+	}
+
+	// X2 adds information beyond X1, while Z doesn't
+	got, _ = Codec(y, [][]float64{x2}, [][]float64{x1}, WithSeed(1))
+	if got < 0.5 {
+		t.Errorf("expected a strong conditional dependence of y on x2 given x1, got: %v", got)
+	}
+	// The estimator is biased downwards when adding irrelevant predictors
+	got, _ = Codec(y, [][]float64{z}, [][]float64{x1, x2}, WithSeed(1))
+	if got > 0.1 {
+		t.Errorf("expected no conditional dependence of y on z given (x1, x2), got: %v", got)
+	}
+
+	// Seeded calculations are reproducible regardless of the number of workers
+	got1, _ := Codec(y, [][]float64{z}, [][]float64{x1}, WithSeed(3), WithWorkers(1))
+	got2, _ := Codec(y, [][]float64{z}, [][]float64{x1}, WithSeed(3), WithWorkers(4))
+	if got1 != got2 {
+		t.Errorf("codec depends on the number of workers; got: %v and %v", got1, got2)
+	}
+}
+
+func TestCodecMissing(t *testing.T) {
+	x1, x2, z, y := codecData(200)
+	want, _ := Codec(y[1:], [][]float64{z[1:]}, [][]float64{x1[1:], x2[1:]}, WithSeed(2))
+
+	x2[0] = math.NaN()
+	got, err := Codec(y, [][]float64{z}, [][]float64{x1, x2}, WithSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("observations with a NaN should be dropped; got: %v, want: %v", got, want)
+	}
+
+	_, err = Codec(y, [][]float64{z}, [][]float64{x1, x2}, WithMissing(MissingError))
+	if err == nil || err.Error() != "xicor: input vectors contain NaN values" {
+		t.Errorf("didn't receive the correct error when providing NaNs with MissingError: %v", err)
+	}
+	_, err = Codec(y, [][]float64{z}, [][]float64{x1, x2}, WithMissing(MissingLast))
+	if err == nil || err.Error() != "xicor: NaNs can only be treated as the largest value in y" {
+		t.Errorf("didn't receive the correct error when providing NaNs in x with MissingLast: %v", err)
+	}
+	_, err = Codec(y, nil, [][]float64{x1})
+	if err == nil || err.Error() != "xicor: no columns given for z" {
+		t.Errorf("didn't receive the correct error when providing no z columns: %v", err)
+	}
+	_, err = Codec(y, [][]float64{z[1:]}, nil)
+	if err == nil || err.Error() != "xicor: mismatched size of input vectors" {
+		t.Errorf("didn't receive the correct error when providing input of different lengths: %v", err)
+	}
+}
+
+func TestNearest(t *testing.T) {
+	columns := [][]float64{{0, 1, 3, 10, 11}, {0, 0, 0, 0, 0}}
+	d := New(nil, nil)
+
+	got, err := d.nearest(context.Background(), columns, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{1, 0, 1, 4, 3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wrong nearest neighbours; got: %v, want: %v", got, want)
+			break
+		}
+	}
+
+	// The middle point has two equally near neighbours, which should both be picked
+	columns = [][]float64{{0, 1, 2}}
+	seen := make(map[int]bool)
+	for seed := int64(0); seed < 50; seed++ {
+		got, _ = d.nearest(context.Background(), columns, seed)
+		seen[got[1]] = true
+	}
+	if !seen[0] || !seen[2] {
+		t.Errorf("ties between nearest neighbours were not broken at random, saw: %v", seen)
+	}
+}
+
+func TestStandardize(t *testing.T) {
+	got := standardize([][]float64{{1, 2, 3}, {5, 5, 5}})
+	assertEpsilon(t, got[0][0], -1)
+	assertEpsilon(t, got[0][2], 1)
+	if got[1][0] != 0 || got[1][2] != 0 {
+		t.Errorf("constant columns should only be centered; got: %v", got[1])
+	}
+}
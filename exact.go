@@ -0,0 +1,140 @@
+package xicor
+
+import (
+	"math"
+	"sort"
+)
+
+// exactLimit is the largest sample size for which `MethodExact` enumerates the permutation distribution.
+const exactLimit = 60
+
+// exactDistribution returns the permutation distribution of the sum S of the distances between the neighbouring max-ranks `r`,
+// with element k holding the probability that S=k when the observations are visited in a uniformly random order.
+//
+// Rather than enumerating the n! orders, the ranks are inserted from the smallest to the largest into a set of growing chains, which are merged into a single path in the end.
+// Every rank adds itself to S once for each neighbour which is smaller, and subtracts itself once for each neighbour which is larger, so that each pair adds the larger rank minus the smaller one.
+// When a rank is inserted, its smaller neighbours are those of the chains it's attached to, and its larger neighbours are those which will be attached to it later,
+// which only depends on the number of chains and of the path's ends already placed. Tied ranks are inserted one after the other, contributing nothing to each other.
+func exactDistribution(r []int) []float64 {
+	n := len(r)
+	if n < 2 {
+		return []float64{1}
+	}
+	v := make([]int, n)
+	copy(v, r)
+	sort.Ints(v)
+
+	// S stays within ±2*sum(v) while the ranks are being inserted
+	var off int
+	for _, vi := range v {
+		off += 2 * vi
+	}
+	width := 2*off + 1
+
+	// dp[j][e][s] counts the ways to arrange the inserted ranks in j chains, e of the path's ends placed, with a partial sum of s-off
+	newTable := func() [][][]float64 {
+		t := make([][][]float64, n+2)
+		for j := range t {
+			t[j] = make([][]float64, 3)
+		}
+		return t
+	}
+	add := func(t [][][]float64, j, e, s int, w float64) {
+		if w == 0 || j < 1 || e > 2 {
+			return
+		}
+		if t[j][e] == nil {
+			t[j][e] = make([]float64, width)
+		}
+		t[j][e][s] += w
+	}
+
+	dp := newTable()
+	dp[0][0] = make([]float64, width)
+	dp[0][0][off] = 1
+	for i, vi := range v {
+		next := newTable()
+		for j := 0; j <= i; j++ {
+			for e := 0; e <= 2; e++ {
+				row := dp[j][e]
+				if row == nil {
+					continue
+				}
+				for s, w := range row {
+					if w == 0 {
+						continue
+					}
+					// A new chain in between the others, with two larger neighbours to come
+					add(next, j+1, e, s-2*vi, w*float64(j+1-e))
+					// A new chain at one of the path's ends, with one larger neighbour to come
+					add(next, j+1, e+1, s-vi, w*float64(2-e))
+					if j == 0 {
+						continue
+					}
+					// Attached to one side of a chain, with one smaller and one larger neighbour
+					add(next, j, e, s, w*float64(2*j-e))
+					// Attached to the outer side of the first or last chain, as one of the path's ends
+					add(next, j, e+1, s+vi, w*float64(2-e))
+					// Merging two neighbouring chains, with two smaller neighbours
+					add(next, j-1, e, s+2*vi, w*float64(j-1))
+				}
+			}
+		}
+		dp = next
+	}
+
+	row := dp[1][2]
+	var total float64
+	for _, w := range row {
+		total += w
+	}
+	dist := make([]float64, off+1)
+	for s, w := range row {
+		if s >= off && w > 0 {
+			dist[s-off] = w / total
+		}
+	}
+	return dist
+}
+
+// exact fills in the exact p-value of the coefficient `s.xi` in `res` under the `alternative` hypothesis, along with the related fields, from the permutation distribution of the max-ranks of Y.
+// As xi is a decreasing function of the sum S of the distances between neighbouring max-ranks, the p-value for a larger xi is the probability of a sum no larger than the observed one, and vice versa.
+func (res *Result) exact(s stats, alternative string) {
+	res.Method = MethodExact
+	// Without a pair of neighbours, or with a constant Y, xi is undefined and so is its p-value
+	if len(s.f) < 2 || math.IsNaN(s.xi) {
+		nan := math.NaN()
+		res.Pvalue, res.LogPvalue, res.SD, res.Statistic = nan, nan, nan, nan
+		return
+	}
+
+	n := float64(len(s.f))
+	r := make([]int, len(s.f))
+	for i, v := range s.f {
+		r[i] = int(math.Round(v * n))
+	}
+	dist := exactDistribution(r)
+
+	// Recover the observed sum from xi, rounding off the error of the floating-point operations
+	xi := func(sum int) float64 {
+		return 1 - float64(sum)/n/(2*n)/s.cval
+	}
+	observed := int(math.Round((1 - s.xi) * s.cval * 2 * n * n))
+
+	var greater, less, m1, m2 float64
+	for sum, p := range dist {
+		if sum <= observed {
+			greater += p
+		}
+		if sum >= observed {
+			less += p
+		}
+		m1 += p * xi(sum)
+		m2 += p * xi(sum) * xi(sum)
+	}
+
+	greater, less = math.Min(greater, 1), math.Min(less, 1)
+	res.alternative(alternative, greater, math.Log(greater), less, math.Log(less))
+	res.SD = math.Sqrt(math.Max(m2-m1*m1, 0))
+	res.Statistic = res.Xi / res.SD
+}
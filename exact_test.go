@@ -0,0 +1,139 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// permute calls fn with every permutation of a, using Heap's algorithm.
+func permute(a []int, k int, fn func([]int)) {
+	if k <= 1 {
+		fn(a)
+		return
+	}
+	for i := 0; i < k; i++ {
+		permute(a, k-1, fn)
+		if k%2 == 0 {
+			a[i], a[k-1] = a[k-1], a[i]
+		} else {
+			a[0], a[k-1] = a[k-1], a[0]
+		}
+	}
+}
+
+func TestExactDistribution(t *testing.T) {
+	tcs := map[string][]int{
+		"pair":     {1, 2},
+		"distinct": {1, 2, 3, 4, 5, 6, 7},
+		"ties":     {2, 2, 3, 5, 5, 7, 7},
+		"constant": {4, 4, 4, 4},
+	}
+	for name, r := range tcs {
+		want := make([]float64, len(r)*len(r))
+		var count float64
+		permute(append([]int(nil), r...), len(r), func(p []int) {
+			var sum int
+			for i := 0; i < len(p)-1; i++ {
+				sum += int(abs(float64(p[i] - p[i+1])))
+			}
+			want[sum]++
+			count++
+		})
+
+		got := exactDistribution(r)
+		for sum := range want {
+			var g float64
+			if sum < len(got) {
+				g = got[sum]
+			}
+			if math.Abs(g-want[sum]/count) > 1e-12 {
+				t.Errorf("%s: wrong probability of S=%d; got: %v, want: %v", name, sum, g, want[sum]/count)
+			}
+		}
+	}
+}
+
+func TestExactPvalue(t *testing.T) {
+	for _, pair := range [][2]string{{"x_1", "y_1"}, {"x_3", "y_3"}, {"y_4", "x_4"}} {
+		x, y := anscombesQuartet[pair[0]], anscombesQuartet[pair[1]]
+		if len(x) > 8 {
+			x, y = x[:8], y[:8]
+		}
+		res, err := Compute(x, y, WithExactPvalue(), WithSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Method != MethodExact || res.Nperms != 0 {
+			t.Errorf("wrong method in result; got: %v with %v permutations", res.Method, res.Nperms)
+		}
+
+		// Walk the max-ranks of y in every possible order
+		s, _ := correlation(x, y, 1, rand.New(rand.NewSource(1)))
+		idx := make([]int, len(x))
+		for i := range idx {
+			idx[i] = i
+		}
+		fp := make([]float64, len(x))
+		var above, count float64
+		permute(idx, len(idx), func(p []int) {
+			for i, j := range p {
+				fp[i] = s.f[j]
+			}
+			if s.permuted(fp) >= res.Xi-1e-12 {
+				above++
+			}
+			count++
+		})
+		assertEpsilon(t, res.Pvalue, above/count)
+	}
+
+	// The exact distribution should be close to the asymptotic one for moderate samples
+	exact, err := Compute(xx[:60], yy[:60], WithExactPvalue(), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	asymptotic, _ := Compute(xx[:60], yy[:60], WithSeed(1))
+	if math.Abs(exact.SD/asymptotic.SD-1) > 0.1 {
+		t.Errorf("exact standard deviation is too far from the asymptotic one; got: %v, want: %v", exact.SD, asymptotic.SD)
+	}
+
+	// A constant Y or a single pair leave xi undefined, and the p-value with it
+	for name, y := range map[string][]float64{"constant": {2, 2, 2, 2, 2}, "single": {1}} {
+		res, err := Compute(xx[:len(y)], y, WithExactPvalue())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !math.IsNaN(res.Pvalue) || !math.IsNaN(res.LogPvalue) {
+			t.Errorf("%s: expected an undefined p-value, got: %v and %v", name, res.Pvalue, res.LogPvalue)
+		}
+	}
+}
+
+func TestExactErrors(t *testing.T) {
+	tcs := map[string]struct {
+		x, y    []float64
+		options []func(*Xi)
+		err     string
+	}{
+		"large":     {xx[:61], yy[:61], nil, "xicor: the exact p-value is only available for up to 60 observations; use either the asymptotic or the permutation method"},
+		"symmetric": {xx[:10], yy[:10], []func(*Xi){WithSymmetric()}, "xicor: the exact p-value is only available for the original coefficient"},
+		"revised":   {xx[:10], yy[:10], []func(*Xi){WithRevised(2)}, "xicor: the exact p-value is only available for the original coefficient"},
+		"default":   {xx[:40], yy[:40], []func(*Xi){WithRevised(0)}, "xicor: the exact p-value is only available for the original coefficient"},
+	}
+	for name, tc := range tcs {
+		_, err := Compute(tc.x, tc.y, append(tc.options, WithExactPvalue())...)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: didn't receive the correct error: %v", name, err)
+		}
+	}
+
+	// A single neighbour, whether set or by default for a small sample, is the original coefficient
+	want, _ := Compute(xx[:20], yy[:20], WithExactPvalue(), WithSeed(1))
+	for _, m := range []int{1, 0} {
+		got, err := Compute(xx[:20], yy[:20], WithRevised(m), WithExactPvalue(), WithSeed(1))
+		if err != nil || got.Pvalue != want.Pvalue {
+			t.Errorf("revised with m=%v: got p-value %v, want: %v, error: %v", m, got.Pvalue, want.Pvalue, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package xicor
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+)
+
+// Factor encodes categorical labels as integer level codes starting from 1, like R's `as.numeric(factor(x))`, so that categorical columns can be used for X and/or Y.
+// By default the levels are the distinct labels in ascending order, which is also how `ComputeOrdered` ranks labels passed in directly.
+// Passing `levels` sets a custom ordering of the levels; labels which aren't one of the levels are encoded as NaN and handled by the missing-value policy.
+func Factor[T cmp.Ordered](labels []T, levels ...T) ([]float64, error) {
+	if len(levels) == 0 {
+		levels = distinct(labels)
+	}
+
+	codes := make(map[T]float64, len(levels))
+	for i, level := range levels {
+		if isNaN(level) {
+			return nil, fmt.Errorf("xicor: factor level %d is NaN", i+1)
+		}
+		if _, ok := codes[level]; ok {
+			return nil, fmt.Errorf("xicor: factor level %d is duplicated", i+1)
+		}
+		codes[level] = float64(i + 1)
+	}
+
+	res := make([]float64, len(labels))
+	for i, label := range labels {
+		code, ok := codes[label]
+		if !ok {
+			code = math.NaN()
+		}
+		res[i] = code
+	}
+	return res, nil
+}
+
+// distinct returns the distinct values of `a` in ascending order, leaving out NaNs.
+func distinct[T cmp.Ordered](a []T) []T {
+	var res []T
+	idx := sortedIndex(a)
+	tieBlocks(a, idx, func(lo, hi int) {
+		if val := a[idx[lo]]; !isNaN(val) {
+			res = append(res, val)
+		}
+	})
+	return res
+}
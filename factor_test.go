@@ -0,0 +1,69 @@
+package xicor
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFactor(t *testing.T) {
+	labels := []string{"low", "high", "medium", "low", "high"}
+
+	got, err := Factor(labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{2, 1, 3, 2, 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result for Factor with input:%v, got:%v, want:%v", labels, got, want)
+	}
+
+	got, err = Factor(labels, "low", "medium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []float64{1, math.NaN(), 2, 1, math.NaN()}
+	for i := range want {
+		if got[i] != want[i] && !(math.IsNaN(got[i]) && math.IsNaN(want[i])) {
+			t.Errorf("wrong result for Factor with custom levels, got:%v, want:%v", got, want)
+			break
+		}
+	}
+
+	_, err = Factor(labels, "low", "medium", "low")
+	if err == nil || err.Error() != "xicor: factor level 3 is duplicated" {
+		t.Errorf("didn't receive the correct error when providing duplicated levels: %v", err)
+	}
+
+	codes, _ := Factor([]float64{2.5, math.NaN(), -1, 2.5})
+	if !reflect.DeepEqual(codes[2:], []float64{1, 2}) || codes[0] != 2 || !math.IsNaN(codes[1]) {
+		t.Errorf("wrong result for Factor with float labels, got:%v", codes)
+	}
+}
+
+func TestFactorCorrelation(t *testing.T) {
+	labels := []string{"b", "a", "c", "a", "c", "b", "a", "c", "b", "a"}
+	y := []float64{2.1, 1.2, 3.3, 0.9, 2.8, 2.2, 1.1, 3.1, 1.9, 1.0}
+
+	// Default levels rank like the labels themselves
+	codes, _ := Factor(labels)
+	want, _ := ComputeOrdered(labels, y, WithSeed(8))
+	got, _ := Compute(codes, y, WithSeed(8))
+	if got != want {
+		t.Errorf("factor codes don't correlate like the raw labels; got: %+v, want: %+v", got, want)
+	}
+
+	// The level ordering changes which categories are neighbours
+	codes, _ = Factor(labels, "a", "c", "b")
+	reordered, _ := Compute(codes, y, WithSeed(8))
+	if reordered.Xi >= got.Xi {
+		t.Errorf("expected a weaker dependence with levels out of order; got: %v, ordered: %v", reordered.Xi, got.Xi)
+	}
+
+	// Labels outside the levels are dropped as missing values
+	codes, _ = Factor(labels, "a", "b")
+	res, _ := Compute(codes, y)
+	if res.Dropped != 3 {
+		t.Errorf("wrong number of dropped pairs; got: %v, want: %v", res.Dropped, 3)
+	}
+}
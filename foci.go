@@ -0,0 +1,98 @@
+package xicor
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// Selection holds the outcome of the FOCI feature selection.
+type Selection struct {
+	// Features holds the indices of the selected columns, in the order they were selected.
+	Features []int
+	// Coefficients holds the conditional dependence coefficient T(Y, X_j | previously selected features) of each feature at the step it was selected.
+	Coefficients []float64
+}
+
+// SelectFeatures runs FOCI (Feature Ordering by Conditional Independence) from Azadkia and Chatterjee (arxiv.org/abs/1910.12327), configured by the same functional options as `New`.
+// Starting from no features, it repeatedly selects the column of x which adds the most to the dependence of y on the features selected so far,
+// and stops once no remaining column has a positive conditional dependence coefficient, or when `MaxFeatures` have been selected.
+//
+// The candidate columns of each step are evaluated in parallel across `Workers` goroutines; like `Codec`, each evaluation runs a brute-force nearest neighbour search in O(n²) time.
+func SelectFeatures(y []float64, x [][]float64, options ...func(*Xi)) (Selection, error) {
+	return SelectFeaturesContext(context.Background(), y, x, options...)
+}
+
+// SelectFeaturesContext is like `SelectFeatures`, but stops early and returns the context's error once `ctx` is done.
+func SelectFeaturesContext(ctx context.Context, y []float64, x [][]float64, options ...func(*Xi)) (Selection, error) {
+	d := New(nil, nil, options...)
+	x, err := d.columns(x)
+	if err != nil {
+		return Selection{}, err
+	}
+	if len(x) == 0 {
+		return Selection{}, errors.New("xicor: no candidate features given")
+	}
+	y, sets, err := cleanColumns(d, y, x)
+	if err != nil {
+		return Selection{}, err
+	}
+	x = sets[0]
+	if d.Standardize {
+		x = standardize(x)
+	}
+
+	// The candidates are evaluated in parallel, so each nearest neighbour search runs on a single goroutine
+	single := *d
+	single.Workers = 1
+	c := newCodec(&single, y)
+
+	maxFeatures := d.MaxFeatures
+	if maxFeatures <= 0 || maxFeatures > len(x) {
+		maxFeatures = len(x)
+	}
+
+	var sel Selection
+	selected := make([]bool, len(x))
+	var columns [][]float64
+	var qPrev float64
+	qs := make([]float64, len(x))
+	errs := make([]error, len(x))
+	for step := 0; step < maxFeatures; step++ {
+		err := forEach(ctx, d.Workers, len(x), func(_, j int) {
+			if selected[j] {
+				return
+			}
+			candidate := append(append(make([][]float64, 0, len(columns)+1), columns...), x[j])
+			qs[j], errs[j] = c.q(ctx, candidate, step*len(x)+j)
+		})
+		if err != nil {
+			return Selection{}, err
+		}
+
+		best, qBest := -1, math.Inf(-1)
+		for j := range x {
+			if errs[j] != nil {
+				return Selection{}, errs[j]
+			}
+			if !selected[j] && qs[j] > qBest {
+				best, qBest = j, qs[j]
+			}
+		}
+		if qBest <= qPrev {
+			break
+		}
+
+		coef := qBest / c.s
+		if step > 0 {
+			coef = c.conditional(qPrev, qBest)
+		}
+		sel.Features = append(sel.Features, best)
+		sel.Coefficients = append(sel.Coefficients, coef)
+		selected[best] = true
+		columns = append(columns, x[best])
+		qPrev = qBest
+	}
+
+	return sel, nil
+}
@@ -0,0 +1,82 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func fociData(n int) ([]float64, [][]float64) {
+	r := rand.New(rand.NewSource(21))
+	x := make([][]float64, 6)
+	for k := range x {
+		x[k] = make([]float64, n)
+		for i := range x[k] {
+			x[k][i] = r.Float64()
+		}
+	}
+	y := make([]float64, n)
+	for i := range y {
+		y[i] = math.Sin(4*x[3][i]) + 2*x[1][i]*x[1][i] + 0.05*r.NormFloat64()
+	}
+	return y, x
+}
+
+func TestSelectFeatures(t *testing.T) {
+	y, x := fociData(500)
+
+	got, err := SelectFeatures(y, x, WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Features) < 2 || len(got.Features) != len(got.Coefficients) {
+		t.Fatalf("expected at least two selected features, got: %+v", got)
+	}
+	first := []int{got.Features[0], got.Features[1]}
+	if !(reflect.DeepEqual(first, []int{1, 3}) || reflect.DeepEqual(first, []int{3, 1})) {
+		t.Errorf("expected features 1 and 3 to be selected first, got: %v", got.Features)
+	}
+	for k, coef := range got.Coefficients {
+		if coef <= 0 {
+			t.Errorf("selected feature %v with a non-positive coefficient %v", got.Features[k], coef)
+		}
+	}
+
+	// The first coefficient is the unconditional dependence on the first feature
+	want, _ := Codec(y, [][]float64{x[got.Features[0]]}, nil, WithSeed(1))
+	if abs(got.Coefficients[0]-want) > 0.05 {
+		t.Errorf("wrong coefficient for the first feature; got: %v, want about: %v", got.Coefficients[0], want)
+	}
+
+	capped, _ := SelectFeatures(y, x, WithSeed(1), WithMaxFeatures(1))
+	if !reflect.DeepEqual(capped.Features, got.Features[:1]) {
+		t.Errorf("wrong selection when capped to one feature; got: %v, want: %v", capped.Features, got.Features[:1])
+	}
+
+	parallel, _ := SelectFeatures(y, x, WithSeed(1), WithWorkers(4))
+	if !reflect.DeepEqual(parallel, got) {
+		t.Errorf("the selection depends on the number of workers; got: %+v, want: %+v", parallel, got)
+	}
+
+	_, err = SelectFeatures(y, nil)
+	if err == nil || err.Error() != "xicor: no candidate features given" {
+		t.Errorf("didn't receive the correct error when providing no features: %v", err)
+	}
+}
+
+func TestSelectFeaturesIndependent(t *testing.T) {
+	y, x := fociData(300)
+	r := rand.New(rand.NewSource(5))
+	for i := range y {
+		y[i] = r.NormFloat64()
+	}
+
+	got, err := SelectFeatures(y, x, WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Features) > 2 {
+		t.Errorf("expected few features to be selected for an independent response, got: %v", got.Features)
+	}
+}
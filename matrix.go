@@ -0,0 +1,136 @@
+package xicor
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+)
+
+// Matrix calculates xi for every ordered pair of `columns`, configured by the same functional options as `New`.
+// It returns p×p matrices of the coefficients and their p-values, where element [i][j] measures how much column j is a function of column i; as xi isn't symmetric, neither are the matrices.
+// The p-values are left at zero when `WantPvalue=false`.
+//
+// Each column is ranked only once, and its asymptotic variance or permutation distribution is shared by all the pairs in which it plays the role of Y.
+// When the default missing-value policy drops pairs, the columns containing NaNs are instead recomputed for each pair.
+// `Progress` is called with the number of rows of the matrices completed so far.
+func Matrix(columns [][]float64, options ...func(*Xi)) ([][]float64, [][]float64, error) {
+	return MatrixContext(context.Background(), columns, options...)
+}
+
+// MatrixContext is like `Matrix`, but stops early and returns the context's error once `ctx` is done.
+func MatrixContext(ctx context.Context, columns [][]float64, options ...func(*Xi)) ([][]float64, [][]float64, error) {
+	d := New(nil, nil, options...)
+	// The calculations of the columns report as a whole rather than each on its own
+	progress := d.Progress
+	d.Progress = nil
+	pvalue := d.WantPvalue
+	if err := d.validate(pvalue); err != nil {
+		return nil, nil, err
+	}
+	if d.Symmetric {
+		return nil, nil, errors.New("xicor: Matrix doesn't support the symmetric coefficient; use the maximum of xis[i][j] and xis[j][i]")
+	}
+	if d.Null != nil {
+		return nil, nil, errors.New("xicor: Matrix doesn't support a null distribution, as every column plays the role of Y")
+	}
+
+	p := len(columns)
+	pairwise := make([]bool, p)
+	for k, col := range columns {
+		if len(col) != len(columns[0]) {
+			return nil, nil, errors.New("xicor: mismatched size of input vectors")
+		}
+		if _, _, err := clean(d, col, col); err != nil {
+			return nil, nil, err
+		}
+		pairwise[k] = (d.Missing == "" || d.Missing == MissingDrop) && hasNaN(col)
+	}
+
+	// Every column gets its own streams, derived from a single seed
+	seed := d.streamSeed(d.rng())
+
+	// Rank each column once, both as X and as Y
+	ords := make([][]int, p)
+	cols := make([]stats, p)
+	err := forEach(ctx, d.Workers, p, func(_, k int) {
+		if pairwise[k] {
+			return
+		}
+		ord, f, g, ties := ranks(columns[k], rand.New(rand.NewSource(chunkSeed(seed, k))))
+		ords[k] = ord
+		cols[k] = fromRanks(f, g, ties)
+		cols[k].m = d.neighbours(len(columns[k]))
+		if pvalue && d.DataTies && d.Method == MethodAsymptotic {
+			cols[k].v = asymptoticVariance(cols[k].f, cols[k].cval)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The permutation distributions are spread across the workers themselves
+	if pvalue && d.DataTies && d.Method == MethodPermutation {
+		for k := range cols {
+			if pairwise[k] {
+				continue
+			}
+			cols[k].rp, err = d.permutations(ctx, cols[k], chunkSeed(seed, p+k), nil)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	xis := square(p)
+	pvals := square(p)
+	errs := make([]error, p)
+	var mu sync.Mutex
+	var done int
+	err = forEach(ctx, d.Workers, p, func(_, i int) {
+		for j := 0; j < p; j++ {
+			var res Result
+			if pairwise[i] || pairwise[j] {
+				dd := *d
+				dd.seed, dd.seeded = chunkSeed(seed, 2*p+i*p+j), true
+				dd.Workers = 1
+				res, errs[i] = compute(ctx, &dd, columns[i], columns[j], pvalue, false)
+			} else {
+				s := cols[j]
+				s.xi = s.walk(ords[i])
+				res.Xi = s.xi
+				if pvalue {
+					errs[i] = d.test(ctx, &res, s, 0)
+				}
+			}
+			if errs[i] != nil {
+				return
+			}
+			xis[i][j], pvals[i][j] = res.Xi, res.Pvalue
+		}
+		if progress != nil {
+			mu.Lock()
+			done++
+			progress(done, p)
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return xis, pvals, nil
+}
+
+func square(p int) [][]float64 {
+	res := make([][]float64, p)
+	for i := range res {
+		res[i] = make([]float64, p)
+	}
+	return res
+}
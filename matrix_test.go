@@ -0,0 +1,131 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func matrixColumns(n int) [][]float64 {
+	r := rand.New(rand.NewSource(4))
+	columns := make([][]float64, 3)
+	for k := range columns {
+		columns[k] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		columns[0][i] = r.NormFloat64()
+		columns[1][i] = columns[0][i]*columns[0][i] + 0.1*r.NormFloat64()
+		columns[2][i] = r.NormFloat64()
+	}
+	return columns
+}
+
+func TestMatrix(t *testing.T) {
+	columns := matrixColumns(200)
+
+	xis, pvals, err := Matrix(columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range columns {
+		for j := range columns {
+			want, _ := Compute(columns[i], columns[j])
+			assertEpsilon(t, xis[i][j], want.Xi)
+			assertEpsilon(t, pvals[i][j], want.Pvalue)
+		}
+	}
+
+	// The revised coefficient shares the ranks in the same way
+	revised, _, err := Matrix(columns, WithRevised(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range columns {
+		for j := range columns {
+			want, _ := Compute(columns[i], columns[j], WithRevised(3))
+			assertEpsilon(t, revised[i][j], want.Xi)
+		}
+	}
+
+	// Y=X^2 is a function of X, but not the other way around
+	if xis[0][1] < 0.5 || xis[1][0] > 0.3 {
+		t.Errorf("expected an asymmetric dependence between the first two columns, got: %v and %v", xis[0][1], xis[1][0])
+	}
+
+	// Columns with NaNs are recomputed pairwise
+	columns[2][0] = math.NaN()
+	xis, pvals, err = Matrix(columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range columns {
+		for j := range columns {
+			want, _ := Compute(columns[i], columns[j])
+			assertEpsilon(t, xis[i][j], want.Xi)
+			assertEpsilon(t, pvals[i][j], want.Pvalue)
+		}
+	}
+
+	_, _, err = Matrix(columns, WithMissing(MissingError))
+	if err == nil || err.Error() != "xicor: input vectors contain NaN values" {
+		t.Errorf("didn't receive the correct error when providing NaNs with MissingError: %v", err)
+	}
+
+	_, _, err = Matrix([][]float64{{1, 2, 3}, {1, 2}})
+	if err == nil || err.Error() != "xicor: mismatched size of input vectors" {
+		t.Errorf("didn't receive the correct error when providing columns of different lengths: %v", err)
+	}
+
+	null, _ := NewNullDistribution(columns[0], WithPermutationPvalue(10))
+	_, _, err = Matrix(columns, WithNullDistribution(null))
+	if err == nil || err.Error() != "xicor: Matrix doesn't support a null distribution, as every column plays the role of Y" {
+		t.Errorf("didn't receive the correct error when providing a null distribution: %v", err)
+	}
+}
+
+func TestMatrixProgress(t *testing.T) {
+	columns := matrixColumns(100)
+	columns[1][3] = math.NaN()
+
+	// The rows are reported as they're completed, even when the columns with NaNs are recomputed for every pair in parallel
+	var calls, last int
+	progress := func(done, total int) {
+		if done != last+1 || total != len(columns) {
+			t.Errorf("unexpected progress report: %v out of %v after %v", done, total, last)
+		}
+		calls++
+		last = done
+	}
+	_, _, err := Matrix(columns, WithPermutationPvalue(300), WithWorkers(3), WithProgress(progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(columns) || last != len(columns) {
+		t.Errorf("wrong progress reports; got %v calls ending at %v", calls, last)
+	}
+}
+
+func TestMatrixPermutation(t *testing.T) {
+	columns := matrixColumns(100)
+	columns[2][5] = math.NaN()
+
+	xis1, pvals1, err := Matrix(columns, WithPermutationPvalue(300), WithSeed(2), WithWorkers(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xis2, pvals2, err := Matrix(columns, WithPermutationPvalue(300), WithSeed(2), WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(xis1, xis2) || !reflect.DeepEqual(pvals1, pvals2) {
+		t.Error("the matrices depend on the number of workers")
+	}
+
+	if pvals1[0][1] != 1./301. {
+		t.Errorf("expected a significant dependence of the second column on the first, got p-value: %v", pvals1[0][1])
+	}
+	if pvals1[0][2] < 0.01 || pvals1[2][0] < 0.01 {
+		t.Errorf("expected no significant dependence between independent columns, got p-values: %v and %v", pvals1[0][2], pvals1[2][0])
+	}
+}
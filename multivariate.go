@@ -0,0 +1,43 @@
+package xicor
+
+import (
+	"context"
+	"errors"
+)
+
+// Multivariate calculates the dependence of y on the vector of predictors x, using the unconditional coefficient T(Y, X) of Azadkia and Chatterjee (arxiv.org/abs/1910.12327),
+// configured by the same functional options as `New`. Like xi, it ranges from 0 when Y is independent of X, to 1 when Y is a function of X.
+//
+// Instead of ordering a single X, each observation is compared to its nearest neighbour in the space of the predictors; by default the Euclidean distance between
+// the standardized predictors is used, which `WithDistance` and `WithoutStandardization` change. Ties between neighbours are broken at random, drawing from the same
+// source as the rest of the calculation. x is a set of columns, each as long as y, or a set of rows when `WithRowMajor` is used.
+func Multivariate(x [][]float64, y []float64, options ...func(*Xi)) (float64, error) {
+	return MultivariateContext(context.Background(), x, y, options...)
+}
+
+// MultivariateContext is like `Multivariate`, but stops early and returns the context's error once `ctx` is done.
+func MultivariateContext(ctx context.Context, x [][]float64, y []float64, options ...func(*Xi)) (float64, error) {
+	d := New(nil, nil, options...)
+	x, err := d.columns(x)
+	if err != nil {
+		return 0, err
+	}
+	if len(x) == 0 {
+		return 0, errors.New("xicor: no columns given for x")
+	}
+	y, sets, err := cleanColumns(d, y, x)
+	if err != nil {
+		return 0, err
+	}
+	x = sets[0]
+	if d.Standardize {
+		x = standardize(x)
+	}
+
+	c := newCodec(d, y)
+	q, err := c.q(ctx, x, 0)
+	if err != nil {
+		return 0, err
+	}
+	return q / c.s, nil
+}
@@ -0,0 +1,76 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMultivariate(t *testing.T) {
+	x1, x2, z, y := codecData(500)
+
+	got, err := Multivariate([][]float64{x1, x2}, y, WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Codec(y, [][]float64{x1, x2}, nil, WithSeed(1))
+	if got != want {
+		t.Errorf("wrong result for Multivariate; got: %v, want: %v", got, want)
+	}
+
+	// The same predictors given row by row
+	rows := make([][]float64, len(y))
+	for i := range rows {
+		rows[i] = []float64{x1[i], x2[i]}
+	}
+	byRow, err := Multivariate(rows, y, WithSeed(1), WithRowMajor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byRow != got {
+		t.Errorf("row-major input gives a different result; got: %v, want: %v", byRow, got)
+	}
+
+	indep, _ := Multivariate([][]float64{z}, y, WithSeed(1))
+	if indep > 0.1 || got < 0.7 {
+		t.Errorf("expected a strong dependence on (x1, x2) and none on z; got: %v and %v", got, indep)
+	}
+
+	rows[3] = []float64{1}
+	_, err = Multivariate(rows, y, WithRowMajor())
+	if err == nil || err.Error() != "xicor: rows of the multivariate predictor have different lengths" {
+		t.Errorf("didn't receive the correct error when providing ragged rows: %v", err)
+	}
+}
+
+func TestMultivariateDistance(t *testing.T) {
+	// y only depends on the first coordinate; a distance which ignores the second one finds better neighbours
+	r := rand.New(rand.NewSource(3))
+	x1 := make([]float64, 300)
+	x2 := make([]float64, 300)
+	y := make([]float64, 300)
+	for i := range y {
+		x1[i] = r.Float64()
+		x2[i] = r.Float64()
+		y[i] = math.Sin(6 * x1[i])
+	}
+
+	euclidean, _ := Multivariate([][]float64{x1, x2}, y, WithSeed(1))
+	first, err := Multivariate([][]float64{x1, x2}, y, WithSeed(1), WithDistance(func(a, b []float64) float64 {
+		return abs(a[0] - b[0])
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first <= euclidean {
+		t.Errorf("expected the custom distance to find a stronger dependence; got: %v, Euclidean: %v", first, euclidean)
+	}
+
+	// A distance with many ties still gives reproducible results under a seed
+	coarse := WithDistance(func(a, b []float64) float64 { return math.Round(4 * abs(a[0]-b[0])) })
+	got1, _ := Multivariate([][]float64{x1, x2}, y, WithSeed(2), coarse)
+	got2, _ := Multivariate([][]float64{x1, x2}, y, WithSeed(2), coarse)
+	if got1 != got2 {
+		t.Errorf("seeded calculations with tied neighbours differ; got: %v and %v", got1, got2)
+	}
+}
@@ -0,0 +1,83 @@
+package xicor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPnorm(t *testing.T) {
+	for z, want := range map[float64]float64{0: 0.5, 1.959963984540054: 0.975, -1: 0.15865525393145707, 3: 0.9986501019683699, -40: 0} {
+		assertEpsilon(t, pnorm(z), want)
+	}
+	for _, p := range []float64{0.01, 0.3, 0.5, 0.9} {
+		assertEpsilon(t, pnorm(qnorm(p)), p)
+	}
+}
+
+func TestUpperTail(t *testing.T) {
+	// Reference values from the continued fraction of the Mills ratio
+	tcs := map[float64]float64{
+		10:  -53.23128515051247,
+		30:  -454.32124395634315,
+		40:  -804.6084420137538,
+		100: -5005.524208694205,
+	}
+	for z, want := range tcs {
+		p, logp := upperTail(z)
+		if math.Abs(logp-want) > 1e-9*math.Abs(want) {
+			t.Errorf("wrong log upper tail at %v; got: %v, want: %v", z, logp, want)
+		}
+		if p < 0 || (z < 37 && p == 0) {
+			t.Errorf("wrong upper tail at %v: %v", z, p)
+		}
+	}
+
+	p, logp := upperTail(10)
+	if math.Abs(p/7.619853024160527e-24-1) > 1e-12 || math.Abs(math.Exp(logp)/p-1) > 1e-12 {
+		t.Errorf("wrong upper tail at 10; got: %v", p)
+	}
+
+	// The expansion takes over smoothly from erfc
+	if d := logUpperTail(30) - math.Log(0.5*math.Erfc(30/math.Sqrt2)); math.Abs(d) > 1e-10 {
+		t.Errorf("the asymptotic expansion doesn't match erfc at the switch: %v", d)
+	}
+
+	p, logp = upperTail(-3)
+	assertEpsilon(t, p, 0.9986501019683699)
+	assertEpsilon(t, logp, math.Log(0.9986501019683699))
+}
+
+func TestLogPvalue(t *testing.T) {
+	// Y is a function of X, so the p-value underflows while its logarithm doesn't
+	x := make([]float64, 20000)
+	y := make([]float64, 20000)
+	for i := range x {
+		x[i] = float64(i)
+		y[i] = math.Sin(float64(i) / 1000)
+	}
+	res, err := Compute(x, y, WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Pvalue != 0 || math.IsInf(res.LogPvalue, 0) || res.LogPvalue > -1000 {
+		t.Errorf("expected an underflowing p-value with a finite logarithm; got: %v, %v", res.Pvalue, res.LogPvalue)
+	}
+	_, want := upperTail(res.Statistic)
+	assertEpsilon(t, res.LogPvalue, want)
+
+	sym, err := Compute(x, y, WithSeed(1), WithSymmetric())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsInf(sym.LogPvalue, 0) || sym.LogPvalue > -1000 {
+		t.Errorf("expected a finite logarithm of the symmetric p-value; got: %v", sym.LogPvalue)
+	}
+
+	// The logarithm agrees with the p-value when the latter is representable
+	res, _ = Compute(xx, yy)
+	assertEpsilon(t, math.Exp(res.LogPvalue), res.Pvalue)
+	res, _ = Compute(xx, yy, WithSymmetric())
+	assertEpsilon(t, math.Exp(res.LogPvalue), res.Pvalue)
+	res, _ = Compute(xx, yy, WithPermutationPvalue(200), WithSeed(1))
+	assertEpsilon(t, math.Exp(res.LogPvalue), res.Pvalue)
+}
@@ -0,0 +1,165 @@
+package xicor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+)
+
+// nullMagic starts the binary encoding of a `NullDistribution`, and changes along with its layout.
+const nullMagic = "xicornd1"
+
+// NullDistribution holds the coefficients drawn by the permutation test for a given Y.
+// Under independence, the distribution of xi only depends on the number of observations and on the ties of Y, so it can be drawn once and reused for every X tested against
+// that Y with `WithNullDistribution`. The draws come from the same streams as those of the permutation test, so with the same options and seed, a null distribution gives the same
+// p-values as `Compute` at the cost of a single calculation of xi. It can be saved with `MarshalBinary` and restored with `UnmarshalBinary`.
+type NullDistribution struct {
+	// N is the number of observations, and Neighbours the number of right neighbours each point is compared to.
+	N, Neighbours int
+	// Coefficients holds the permuted coefficients, in the order they were drawn.
+	Coefficients []float64
+
+	// ranks are the sorted integer max-ranks of Y, which describe its ties.
+	ranks []int
+}
+
+// NewNullDistribution draws the permutation distribution of xi for y, configured by the same functional options as `New`.
+// The pairs with a missing X aren't known in advance, so only the NaNs of y are handled by the missing-value policy;
+// testing an X which drops further pairs fails, as the remaining Y has other ties.
+func NewNullDistribution(y []float64, options ...func(*Xi)) (*NullDistribution, error) {
+	return NewNullDistributionContext(context.Background(), y, options...)
+}
+
+// NewNullDistributionContext is like `NewNullDistribution`, but stops early and returns the context's error once `ctx` is done.
+func NewNullDistributionContext(ctx context.Context, y []float64, options ...func(*Xi)) (*NullDistribution, error) {
+	d := New(nil, nil, options...)
+	_, y, err := clean(d, y, y)
+	if err != nil {
+		return nil, err
+	}
+	if len(y) < 2 {
+		return nil, errors.New("xicor: at least two observations are needed")
+	}
+
+	s := prepare(y)
+	s.m = d.neighbours(len(y))
+	rp, err := d.permutations(ctx, s, d.streamSeed(d.rng()), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &NullDistribution{
+		N:            len(y),
+		Neighbours:   s.m,
+		Coefficients: rp,
+		ranks:        tieRanks(s.f),
+	}, nil
+}
+
+// WithNullDistribution makes the p-value be estimated by the permutation test from the coefficients of `null`, rather than from newly drawn ones.
+// The sequential test stops within them, so they should be drawn with the same budget of permutations.
+func WithNullDistribution(null *NullDistribution) func(*Xi) {
+	return func(d *Xi) {
+		d.WantPvalue = true
+		d.Method = MethodPermutation
+		d.Null = null
+		d.withNull = true
+		if null != nil {
+			d.Nperms = len(null.Coefficients)
+		}
+	}
+}
+
+// tieRanks returns the sorted integer max-ranks of the normalized max-ranks `f`.
+func tieRanks(f []float64) []int {
+	n := float64(len(f))
+	r := make([]int, len(f))
+	for i, v := range f {
+		r[i] = int(math.Round(v * n))
+	}
+	slices.Sort(r)
+	return r
+}
+
+// matches reports whether the null distribution was drawn for the max-ranks and neighbours of `s`.
+func (null *NullDistribution) matches(s stats) bool {
+	return len(s.f) == null.N && s.m == null.Neighbours && slices.Equal(tieRanks(s.f), null.ranks)
+}
+
+// MarshalBinary encodes the null distribution, along with the ties of the Y it was drawn for.
+func (null NullDistribution) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(nullMagic)+3*binary.MaxVarintLen64+len(null.ranks)+8*len(null.Coefficients))
+	b = append(b, nullMagic...)
+	b = binary.AppendUvarint(b, uint64(null.N))
+	b = binary.AppendUvarint(b, uint64(null.Neighbours))
+	b = binary.AppendUvarint(b, uint64(len(null.Coefficients)))
+
+	// The sorted ranks are stored as their increments, which are mostly one
+	last := 0
+	for _, r := range null.ranks {
+		b = binary.AppendUvarint(b, uint64(r-last))
+		last = r
+	}
+	for _, v := range null.Coefficients {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a null distribution encoded by `MarshalBinary`.
+func (null *NullDistribution) UnmarshalBinary(data []byte) error {
+	invalid := errors.New("xicor: invalid encoding of the null distribution")
+	if len(data) < len(nullMagic) || string(data[:len(nullMagic)]) != nullMagic {
+		return invalid
+	}
+	data = data[len(nullMagic):]
+
+	next := func() (int, bool) {
+		v, k := binary.Uvarint(data)
+		if k <= 0 || v > math.MaxInt32 {
+			return 0, false
+		}
+		data = data[k:]
+		return int(v), true
+	}
+	n, ok1 := next()
+	m, ok2 := next()
+	nperms, ok3 := next()
+	// Every rank takes at least a byte, so a corrupted size can't make it allocate much
+	if !ok1 || !ok2 || !ok3 || n < 2 || m < 1 || m >= n || n > len(data) {
+		return invalid
+	}
+
+	r := make([]int, n)
+	last := 0
+	for i := range r {
+		step, ok := next()
+		if !ok || last+step > n {
+			return invalid
+		}
+		last += step
+		r[i] = last
+	}
+	if len(data) != 8*nperms {
+		return invalid
+	}
+	// The max-rank of a group of ties is the number of observations up to its last one, which is at least one
+	for lo := 0; lo < n; {
+		hi := lo + 1
+		for hi < n && r[hi] == r[lo] {
+			hi++
+		}
+		if r[lo] != hi {
+			return invalid
+		}
+		lo = hi
+	}
+
+	rp := make([]float64, nperms)
+	for i := range rp {
+		rp[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	*null = NullDistribution{N: n, Neighbours: m, Coefficients: rp, ranks: r}
+	return nil
+}
@@ -0,0 +1,137 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestNullDistribution(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	y := make([]float64, 200)
+	for i := range y {
+		y[i] = float64(r.Intn(40))
+	}
+	null, err := NewNullDistribution(y, WithPermutationPvalue(500), WithSeed(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if null.N != 200 || null.Neighbours != 1 || len(null.Coefficients) != 500 {
+		t.Fatalf("wrong null distribution; got %v observations, %v neighbours and %v coefficients", null.N, null.Neighbours, len(null.Coefficients))
+	}
+
+	// The p-values match those of the permutation test drawn from the same seed, for any X
+	x := make([]float64, len(y))
+	for k := 0; k < 5; k++ {
+		for i := range x {
+			x[i] = float64(k)*y[i] + r.NormFloat64()
+		}
+		want, err := Compute(x, y, WithPermutationPvalue(500), WithSeed(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Compute(x, y, WithNullDistribution(null), WithSeed(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("the null distribution changes the result; got: %+v, want: %+v", got, want)
+		}
+	}
+
+	// Only the ties of Y matter, not its order or values
+	shuffled := make([]float64, len(y))
+	for i, j := range r.Perm(len(y)) {
+		shuffled[i] = 2*y[j] + 1
+	}
+	want, _ := Compute(x, shuffled, WithPermutationPvalue(500), WithSeed(7))
+	got, err := Compute(x, shuffled, WithNullDistribution(null), WithSeed(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Pvalue != want.Pvalue {
+		t.Errorf("the null distribution depends on the order of Y; got: %v, want: %v", got.Pvalue, want.Pvalue)
+	}
+	// The denominator of xi is summed in another order, so the coefficients differ by a rounding error
+	assertEpsilon(t, got.SD, want.SD)
+
+	// The sequential test stops within the coefficients
+	seq, _ := NewNullDistribution(y, WithSequentialPermutationPvalue(5, 2000), WithSeed(7))
+	want, _ = Compute(x, y, WithSequentialPermutationPvalue(5, 2000), WithSeed(7))
+	got, _ = Compute(x, y, WithNullDistribution(seq), WithSequentialPermutationPvalue(5, 2000), WithSeed(7))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the null distribution changes the sequential test; got: %+v, want: %+v", got, want)
+	}
+
+	// The revised coefficient has a null distribution of its own
+	revised, _ := NewNullDistribution(y, WithRevised(3), WithPermutationPvalue(200), WithSeed(7))
+	want, _ = Compute(x, y, WithRevised(3), WithPermutationPvalue(200), WithSeed(7))
+	got, err = Compute(x, y, WithRevised(3), WithNullDistribution(revised), WithSeed(7))
+	if err != nil || got.Pvalue != want.Pvalue {
+		t.Errorf("wrong p-value of the revised coefficient; got: %v, want: %v, error: %v", got.Pvalue, want.Pvalue, err)
+	}
+}
+
+func TestNullDistributionErrors(t *testing.T) {
+	y := []float64{1, 2, 2, 3, 4, 5, 6, 7}
+	null, err := NewNullDistribution(y, WithPermutationPvalue(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := []float64{8, 7, 6, 5, 4, 3, 2, 1}
+	other := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	missing := []float64{1, 2, math.NaN(), 3, 4, 5, 6, 7}
+	tcs := map[string]struct {
+		x, y    []float64
+		options []func(*Xi)
+		err     string
+	}{
+		"ties":      {x, other, nil, "xicor: the null distribution was drawn for a Y with other ties or another number of observations"},
+		"missing":   {missing, y, nil, "xicor: the null distribution was drawn for a Y with other ties or another number of observations"},
+		"revised":   {x, y, []func(*Xi){WithRevised(2)}, "xicor: the null distribution was drawn for a Y with other ties or another number of observations"},
+		"symmetric": {x, y, []func(*Xi){WithSymmetric()}, "xicor: the null distribution is only available for the original coefficient"},
+	}
+	for name, tc := range tcs {
+		_, err := Compute(tc.x, tc.y, append([]func(*Xi){WithNullDistribution(null)}, tc.options...)...)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: didn't receive the correct error: %v", name, err)
+		}
+	}
+
+	if _, err := NewNullDistribution([]float64{1, math.NaN()}); err == nil || err.Error() != "xicor: at least two observations are needed" {
+		t.Errorf("didn't receive the correct error: %v", err)
+	}
+	if _, err := Compute(x, y, WithNullDistribution(nil)); err == nil || err.Error() != "xicor: the null distribution is nil" {
+		t.Errorf("didn't receive the correct error: %v", err)
+	}
+}
+
+func TestNullDistributionBinary(t *testing.T) {
+	y := []float64{3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5}
+	null, err := NewNullDistribution(y, WithPermutationPvalue(100), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := null.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restored NullDistribution
+	if err := restored.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&restored, null) {
+		t.Errorf("the null distribution changed after encoding; got: %+v, want: %+v", restored, *null)
+	}
+
+	// Truncated or corrupted encodings are rejected, as are max-ranks which no Y can have, such as 0, or tied ones below the last of the ties
+	for _, bad := range [][]byte{nil, b[:5], b[:len(b)-1], append(b, 0), append([]byte("xicornd0"), b[8:]...),
+		[]byte("xicornd1\x02\x01\x00\x00\x02"), []byte("xicornd1\x02\x01\x00\x01\x00"), []byte("xicornd1\x03\x01\x00\x01\x01\x00")} {
+		if err := restored.UnmarshalBinary(bad); err == nil {
+			t.Errorf("expected an invalid encoding of length %v to be rejected", len(bad))
+		}
+	}
+}
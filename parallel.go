@@ -0,0 +1,53 @@
+package xicor
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// numWorkers returns the number of goroutines to use for `count` tasks; a non-positive `workers` means `runtime.GOMAXPROCS`.
+func numWorkers(workers, count int) int {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > count {
+		workers = count
+	}
+	return workers
+}
+
+// forEach calls fn for every task in [0, count) from `numWorkers(workers, count)` goroutines.
+// fn also receives the index of the goroutine running it, so that callers can keep per-worker scratch space.
+// Once ctx is done no more tasks are started, and the context's error is returned.
+func forEach(ctx context.Context, workers, count int, fn func(worker, task int)) error {
+	workers = numWorkers(workers, count)
+
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range tasks {
+				if ctx.Err() != nil {
+					continue
+				}
+				fn(w, i)
+			}
+		}(w)
+	}
+
+feed:
+	for i := 0; i < count; i++ {
+		select {
+		case tasks <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(tasks)
+	wg.Wait()
+
+	return ctx.Err()
+}
@@ -0,0 +1,242 @@
+package xicor
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// permChunk is the number of permutations drawn from a single random stream.
+// Keeping it fixed makes the permutation test reproducible regardless of the number of workers.
+const permChunk = 64
+
+// sample draws `nperms` statistics, such as those of the permutation test or the bootstrap.
+// The draws are split in chunks spread across `Workers` goroutines, and chunk `c` draws from its own stream seeded from `seed` and `c`.
+// Every worker gets its own drawing function from `newDraw`, so that it can keep its own scratch space. The context is checked between chunks.
+//
+// When `stop` is set, the chunks are drawn in batches, which start from a single chunk and double up to one chunk per worker;
+// after every batch `stop` receives its draws, in order, and sampling ends as soon as it returns true. The draws up to the end of that batch are returned;
+// as the budget is mostly left unused, the draws only grow one batch at a time.
+func (d *Xi) sample(ctx context.Context, nperms int, seed int64, newDraw func() func(r *rand.Rand) float64, stop func(batch []float64) bool) ([]float64, error) {
+	if nperms < 0 {
+		nperms = 0
+	}
+	size := nperms
+	if stop != nil {
+		size = 0
+	}
+	rp := make([]float64, size)
+	nchunks := (nperms + permChunk - 1) / permChunk
+	workers := numWorkers(d.Workers, nchunks)
+	batch := nchunks
+	if stop != nil {
+		batch = 1
+	}
+
+	var mu sync.Mutex
+	var done int
+	draws := make([]func(r *rand.Rand) float64, workers)
+	for first := 0; first < nchunks; first += batch {
+		if stop != nil && first > 0 && batch < workers {
+			batch *= 2
+		}
+		last := first + batch
+		if last > nchunks {
+			last = nchunks
+		}
+		end := last * permChunk
+		if end > nperms {
+			end = nperms
+		}
+		if len(rp) < end {
+			rp = append(rp, make([]float64, end-len(rp))...)
+		}
+		err := forEach(ctx, d.Workers, last-first, func(w, k int) {
+			if draws[w] == nil {
+				draws[w] = newDraw()
+			}
+
+			c := first + k
+			r := rand.New(rand.NewSource(chunkSeed(seed, c)))
+			hi := (c + 1) * permChunk
+			if hi > nperms {
+				hi = nperms
+			}
+			for i := c * permChunk; i < hi; i++ {
+				rp[i] = draws[w](r)
+			}
+
+			if d.Progress != nil {
+				mu.Lock()
+				done += hi - c*permChunk
+				d.Progress(done, nperms)
+				mu.Unlock()
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if stop != nil && stop(rp[first*permChunk:end]) {
+			return rp[:end], nil
+		}
+	}
+	return rp, nil
+}
+
+// permutations draws `Nperms` correlation coefficients under independence, stopping early when `stop` says so.
+// Since the max-ranks of Y don't change when X is redrawn, each permutation only shuffles the order in which `f` is walked.
+// The shuffles start from the sorted max-ranks, so that the draws only depend on the ties of Y and not on its order, and can be shared through a `NullDistribution`.
+func (d *Xi) permutations(ctx context.Context, s stats, seed int64, stop func(batch []float64) bool) ([]float64, error) {
+	sorted := make([]float64, len(s.f))
+	copy(sorted, s.f)
+	sort.Float64s(sorted)
+	return d.sample(ctx, d.Nperms, seed, func() func(r *rand.Rand) float64 {
+		fp := make([]float64, len(s.f))
+		return func(r *rand.Rand) float64 {
+			copy(fp, sorted)
+			r.Shuffle(len(fp), func(i, j int) { fp[i], fp[j] = fp[j], fp[i] })
+			return s.permuted(fp)
+		}
+	}, stop)
+}
+
+// stopRule returns the rule of the sequential permutation test for the coefficient `xi`, which stops once `Exceedances` permuted coefficients are at least as extreme as xi,
+// counting those within `tol` of xi as ties; it returns nil when the number of permutations is fixed.
+func (d *Xi) stopRule(xi, tol float64) func(batch []float64) bool {
+	if d.Exceedances <= 0 {
+		return nil
+	}
+	// An undefined coefficient is never exceeded, and there's no point in drawing the whole budget for it
+	if math.IsNaN(xi) {
+		return func(batch []float64) bool {
+			return true
+		}
+	}
+	t := tally{xi: xi, tol: tol}
+	return func(batch []float64) bool {
+		for _, v := range batch {
+			t.add(v)
+			if t.extreme(d.Alternative) >= d.Exceedances {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// tally counts the permuted coefficients which are at least as large and at most as large as the observed coefficient `xi`.
+// The coefficients only take the values of a lattice, but those computed from different orders can differ by a rounding error, so the ones within `tol` of xi are ties, which count in both tails.
+type tally struct {
+	xi, tol      float64
+	above, below int
+}
+
+func (t *tally) add(v float64) {
+	if v >= t.xi-t.tol {
+		t.above++
+	}
+	if v <= t.xi+t.tol {
+		t.below++
+	}
+}
+
+// extreme returns the number of permuted coefficients which are at least as extreme as the observed one under the `alternative` hypothesis.
+// For the two-sided alternative, it's the count of the smaller tail.
+func (t tally) extreme(alternative string) int {
+	switch alternative {
+	case AlternativeLess:
+		return t.below
+	case AlternativeTwoSided:
+		if t.above < t.below {
+			return t.above
+		}
+		return t.below
+	default:
+		return t.above
+	}
+}
+
+// permutation fills in the p-value of `res.Xi` under the `alternative` hypothesis and the related fields from the permutation distribution `rp`, treating the coefficients within `tol` of xi as ties.
+// Each tail is estimated as (b+1)/(m+1) for the b of the m permuted coefficients at least as extreme as xi, which counts the observed coefficient as one of the permutations,
+// so that the p-value is never zero and the test keeps its level. The Monte Carlo standard error is that of a binomial proportion.
+//
+// When `h` is positive, the test is the sequential one of Besag and Clifford: it only uses the draws up to the h-th one at least as extreme as xi, and then estimates the tails as b/m instead.
+func (res *Result) permutation(rp []float64, alternative string, h int, tol float64) {
+	// An undefined coefficient, such as that of a constant Y, compares false with every permuted one, which would make it look significant
+	if math.IsNaN(res.Xi) {
+		nan := math.NaN()
+		res.Pvalue, res.LogPvalue, res.MCStdErr = nan, nan, nan
+		res.SD, res.Statistic = sd(rp), nan
+		res.Nperms = len(rp)
+		return
+	}
+
+	t := tally{xi: res.Xi, tol: tol}
+	stopped := false
+	for i := range rp {
+		t.add(rp[i])
+		if h > 0 && t.extreme(alternative) >= h {
+			rp, stopped = rp[:i+1], true
+			break
+		}
+	}
+
+	m := float64(len(rp))
+	greater := float64(t.above+1) / (m + 1)
+	less := float64(t.below+1) / (m + 1)
+	if stopped {
+		greater = float64(t.above) / m
+		less = float64(t.below) / m
+	}
+	res.alternative(alternative, greater, math.Log(greater), less, math.Log(less))
+	res.MCStdErr = mcStdErr(greater, less, m, alternative)
+	res.SD = sd(rp)
+	res.Statistic = res.Xi / res.SD
+	res.Nperms = len(rp)
+}
+
+// mcStdErr returns the Monte Carlo standard error of the p-value of the `alternative` hypothesis, when its tails are estimated as `greater` and `less` from `m` draws.
+func mcStdErr(greater, less, m float64, alternative string) float64 {
+	se := func(p float64) float64 {
+		return math.Sqrt(p * (1 - p) / m)
+	}
+	switch alternative {
+	case AlternativeLess:
+		return se(less)
+	case AlternativeTwoSided:
+		return 2 * se(math.Min(greater, less))
+	default:
+		return se(greater)
+	}
+}
+
+// permuted returns the correlation coefficient obtained when X orders the max-ranks of Y as in `fp`.
+func (s stats) permuted(fp []float64) float64 {
+	var A1 float64
+	for k := 1; k <= s.m; k++ {
+		for i := 0; i < len(fp)-k; i++ {
+			A1 += abs(fp[i] - fp[i+k])
+		}
+	}
+	return s.scale(A1, len(fp))
+}
+
+// tolerance returns the distance below which two coefficients computed from the max-ranks of `s` are taken to be equal; it's a quarter of the smallest difference
+// between two possible values, which are spaced by the change in xi when the sum of the distances between the integer max-ranks of neighbours changes by one.
+func (s stats) tolerance() float64 {
+	n := len(s.f)
+	pairs := float64(s.m*n - s.m*(s.m+1)/2)
+	step := float64(n-1) / (2 * float64(n) * pairs) / (float64(n) * s.cval)
+	return step / 4
+}
+
+// chunkSeed derives the seed of the stream for chunk `c` using the SplitMix64 finalizer, so that neighbouring chunks get unrelated streams.
+func chunkSeed(seed int64, c int) int64 {
+	z := uint64(seed) + uint64(c+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
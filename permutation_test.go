@@ -0,0 +1,264 @@
+package xicor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestPermutationWorkers(t *testing.T) {
+	var want Result
+	for _, workers := range []int{1, 3, 8} {
+		got, err := Compute(xx, yy, WithPermutationPvalue(500), WithSeed(9), WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if workers == 1 {
+			want = got
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("permutation test depends on the number of workers; got: %+v, want: %+v", got, want)
+		}
+	}
+}
+
+func TestPermutations(t *testing.T) {
+	s, _ := correlation(xx, yy, 1, rand.New(rand.NewSource(1)))
+	rp, err := New(xx, yy, WithPermutationPvalue(2000), WithWorkers(4)).permutations(context.Background(), s, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The permuted coefficients should follow the asymptotic null distribution
+	wantSD := math.Sqrt(asymptoticVariance(s.f, s.cval) / float64(len(s.f)))
+	if m := mean(rp); abs(m) > 3*wantSD/math.Sqrt(2000) {
+		t.Errorf("mean of the permutation distribution is too far from zero: %v", m)
+	}
+	if got := sd(rp); abs(got-wantSD) > 0.1*wantSD {
+		t.Errorf("wrong standard deviation of the permutation distribution; got: %v, want: %v", got, wantSD)
+	}
+
+	// The input must not be reordered
+	f := make([]float64, len(s.f))
+	copy(f, s.f)
+	New(xx, yy, WithPermutationPvalue(10), WithWorkers(2)).permutations(context.Background(), s, 1, nil)
+	if !reflect.DeepEqual(f, s.f) {
+		t.Error("permutations modified the max-ranks of Y")
+	}
+}
+
+func TestPermutationContext(t *testing.T) {
+	var calls, last int
+	progress := func(done, total int) {
+		if done <= last || total != 1000 {
+			t.Errorf("unexpected progress report: %v out of %v after %v", done, total, last)
+		}
+		calls++
+		last = done
+	}
+	_, err := Compute(xx, yy, WithPermutationPvalue(1000), WithWorkers(4), WithProgress(progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 1000 || calls != (1000+permChunk-1)/permChunk {
+		t.Errorf("wrong progress reports; got %v calls ending at %v", calls, last)
+	}
+
+	// Cancel the calculation after the first chunk
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls = 0
+	xi := New(xx, yy, WithPermutationPvalue(100_000), WithWorkers(2), WithProgress(func(done, total int) {
+		calls++
+		cancel()
+	}))
+	_, _, err = xi.PvalueContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the calculation to be canceled, got: %v", err)
+	}
+	if calls > 2 {
+		t.Errorf("the calculation continued after being canceled, completed %v chunks", calls)
+	}
+
+	_, err = xi.CorrelationContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the calculation to be canceled, got: %v", err)
+	}
+}
+
+func TestPermutationTies(t *testing.T) {
+	// The permuted coefficients equal to the observed one, up to a rounding error, count in both tails
+	rp := []float64{0.1, 0.3 + 1e-15, 0.3 - 1e-15, 0.5, -0.2}
+	res := Result{Xi: 0.3}
+	res.permutation(rp, AlternativeGreater, 0, 1e-9)
+	assertEpsilon(t, res.Pvalue, 4./6.)
+	assertEpsilon(t, res.MCStdErr, math.Sqrt(4./6.*(2./6.)/5))
+	res.permutation(rp, AlternativeLess, 0, 1e-9)
+	assertEpsilon(t, res.Pvalue, 5./6.)
+	res.permutation(rp, AlternativeTwoSided, 0, 1e-9)
+	assertEpsilon(t, res.Pvalue, 1)
+	assertEpsilon(t, res.MCStdErr, 2*math.Sqrt(4./6.*(2./6.)/5))
+
+	// The tolerance is smaller than the spacing of the possible coefficients, and larger than a rounding error
+	s, _ := correlation(anscombesQuartet["x_1"], anscombesQuartet["y_1"], 1, rand.New(rand.NewSource(1)))
+	n := float64(len(s.f))
+	step := 1 / (2 * n * n * s.cval)
+	if tol := s.tolerance(); tol >= step/2 || tol < 1e-9 {
+		t.Errorf("wrong tolerance; got: %v, spacing: %v", tol, step)
+	}
+
+	// With few observations, many permutations tie with the observed coefficient, and the p-value should match the exact one
+	exact, _ := Compute(anscombesQuartet["x_1"], anscombesQuartet["y_1"], WithExactPvalue())
+	perm, _ := Compute(anscombesQuartet["x_1"], anscombesQuartet["y_1"], WithPermutationPvalue(20_000), WithSeed(4))
+	if abs(perm.Pvalue-exact.Pvalue) > 4*perm.MCStdErr {
+		t.Errorf("permutation p-value is too far from the exact one; got: %v ± %v, want: %v", perm.Pvalue, perm.MCStdErr, exact.Pvalue)
+	}
+}
+
+func TestPermutationUndefined(t *testing.T) {
+	// A constant Y has no p-value, with a fixed or a sequential number of permutations
+	y := make([]float64, 100)
+	for i := range y {
+		y[i] = 3
+	}
+	for name, option := range map[string]func(*Xi){
+		"fixed":      WithPermutationPvalue(999),
+		"sequential": WithSequentialPermutationPvalue(10, 100_000),
+	} {
+		res, err := Compute(xx[:100], y, option, WithSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !math.IsNaN(res.Xi) || !math.IsNaN(res.Pvalue) || !math.IsNaN(res.LogPvalue) || !math.IsNaN(res.MCStdErr) {
+			t.Errorf("%s: expected an undefined p-value; got: %+v", name, res)
+		}
+		if name == "sequential" && res.Nperms > permChunk {
+			t.Errorf("expected the sequential test to stop right away, got: %v permutations", res.Nperms)
+		}
+	}
+
+	// Screening against a constant target keeps it undefined
+	target, err := NewTarget(y, WithPermutationPvalue(99), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	screened, err := target.Screen([][]float64{xx[:100], yy[:100]}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range screened {
+		if !math.IsNaN(s.Result.Pvalue) {
+			t.Errorf("expected an undefined p-value for column %v, got: %v", s.Column, s.Result.Pvalue)
+		}
+	}
+}
+
+func TestSequentialPermutation(t *testing.T) {
+	// Under independence, the test stops after a few permutations
+	r := rand.New(rand.NewSource(1))
+	x := make([]float64, 200)
+	y := make([]float64, 200)
+	for i := range x {
+		x[i] = r.NormFloat64()
+		y[i] = r.NormFloat64()
+	}
+	res, err := Compute(x, y, WithSequentialPermutationPvalue(10, 100_000), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Nperms >= 1000 || res.Pvalue < 0.05 {
+		t.Errorf("expected an early stop with a large p-value; got: %v after %v permutations", res.Pvalue, res.Nperms)
+	}
+	assertEpsilon(t, res.Pvalue, 10/float64(res.Nperms))
+	assertEpsilon(t, res.MCStdErr, math.Sqrt(res.Pvalue*(1-res.Pvalue)/float64(res.Nperms)))
+
+	// The stopping point doesn't depend on the number of workers
+	for _, workers := range []int{1, 3, 8} {
+		got, _ := Compute(x, y, WithSequentialPermutationPvalue(10, 100_000), WithSeed(1), WithWorkers(workers))
+		if !reflect.DeepEqual(got, res) {
+			t.Errorf("sequential test depends on the number of workers; got: %+v, want: %+v", got, res)
+		}
+	}
+
+	// It agrees with the prefix of the fixed-count test drawn from the same streams, which counts the observed coefficient as a permutation
+	fixed, _ := Compute(x, y, WithPermutationPvalue(res.Nperms), WithSeed(1))
+	assertEpsilon(t, fixed.Pvalue, 11/float64(res.Nperms+1))
+
+	// A strong dependence uses the whole budget, and the p-value isn't zero
+	for i := range y {
+		y[i] = x[i] + 0.1*r.NormFloat64()
+	}
+	res, err = Compute(x, y, WithSequentialPermutationPvalue(10, 500), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Nperms != 500 {
+		t.Errorf("expected the whole budget to be used, got: %v permutations", res.Nperms)
+	}
+	assertEpsilon(t, res.Pvalue, 1./501.)
+
+	// The symmetric coefficient and the other alternatives stop in the same way
+	res, _ = Compute(x, y, WithSequentialPermutationPvalue(5, 10_000), WithSeed(1), WithAlternative(AlternativeLess))
+	if res.Nperms > 100 {
+		t.Errorf("expected an early stop for the opposite alternative, got: %v permutations", res.Nperms)
+	}
+	res, _ = Compute(x, y, WithSequentialPermutationPvalue(5, 1000), WithSeed(1), WithSymmetric())
+	if res.Nperms != 1000 || res.Pvalue > 0.01 {
+		t.Errorf("expected the symmetric coefficient to use the whole budget; got: %v after %v permutations", res.Pvalue, res.Nperms)
+	}
+}
+
+func TestSequentialPermutationBudget(t *testing.T) {
+	// A huge budget that's never used costs nothing; sizing the draws to it would take 8 GB
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	res, err := Compute(xx[:200], yy[:200], WithSequentialPermutationPvalue(10, 1_000_000_000), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if res.Nperms >= 10_000 {
+		t.Errorf("expected an early stop, got: %v permutations", res.Nperms)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 10<<20 {
+		t.Errorf("the sequential test allocated %v bytes for %v permutations", alloc, res.Nperms)
+	}
+
+	// Growing the draws doesn't change them
+	fixed, _ := Compute(xx[:200], yy[:200], WithPermutationPvalue(res.Nperms), WithSeed(1))
+	assertEpsilon(t, fixed.SD, res.SD)
+}
+
+func TestChunkSeed(t *testing.T) {
+	seen := make(map[int64]bool)
+	for c := -1; c < 1000; c++ {
+		seen[chunkSeed(42, c)] = true
+	}
+	if len(seen) != 1001 {
+		t.Errorf("chunk seeds collide; got %v distinct seeds out of 1001", len(seen))
+	}
+}
+
+func BenchmarkPermutation(b *testing.B) {
+	b.ReportAllocs()
+	x := make([]float64, 100_000)
+	y := make([]float64, 100_000)
+
+	for i := range x {
+		x[i] = rand.NormFloat64()
+		y[i] = rand.NormFloat64()
+	}
+
+	var c, p float64
+	xi := New(x, y, WithPermutationPvalue(200))
+	for i := 0; i < b.N; i++ {
+		c, p, _ = xi.Pvalue()
+	}
+	corr = c
+	pvalue = p
+}
@@ -0,0 +1,117 @@
+package xicor
+
+import (
+	"cmp"
+	"math/rand"
+	"sort"
+)
+
+// All ranking functions share a single ordering of values: -0 and +0 are tied, infinities sort at the ends,
+// and NaNs are tied with each other and sort after every other value.
+// Non floating-point types are ordered by their natural order.
+func less[T cmp.Ordered](a, b T) bool {
+	if isNaN(a) {
+		return false
+	}
+	if isNaN(b) {
+		return true
+	}
+	return a < b
+}
+
+// isNaN reports whether `v` is a floating-point NaN, the only value which isn't equal to itself.
+func isNaN[T cmp.Ordered](v T) bool {
+	return v != v
+}
+
+type byValue[T cmp.Ordered] struct {
+	a   []T
+	idx []int
+}
+
+func (s byValue[T]) Len() int           { return len(s.idx) }
+func (s byValue[T]) Less(i, j int) bool { return less(s.a[s.idx[i]], s.a[s.idx[j]]) }
+func (s byValue[T]) Swap(i, j int)      { s.idx[i], s.idx[j] = s.idx[j], s.idx[i] }
+
+// sortedIndex returns the indices of `a` in ascending order of their values, without modifying `a`.
+func sortedIndex[T cmp.Ordered](a []T) []int {
+	idx := make([]int, len(a))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Sort(byValue[T]{a: a, idx: idx})
+	return idx
+}
+
+// tieBlocks calls fn with the bounds [lo, hi) of each block of tied values in the sorted index `idx`.
+func tieBlocks[T cmp.Ordered](a []T, idx []int, fn func(lo, hi int)) {
+	lo := 0
+	for i := 1; i <= len(idx); i++ {
+		if i == len(idx) || less(a[idx[lo]], a[idx[i]]) {
+			fn(lo, i)
+			lo = i
+		}
+	}
+}
+
+// argsort returns the indices that would sort `a`, with ties broken at random, along with the number of groups of tied values.
+func argsort[T cmp.Ordered](a []T, r *rand.Rand) ([]int, int) {
+	idx := sortedIndex(a)
+	var ties int
+	tieBlocks(a, idx, func(lo, hi int) {
+		if hi-lo > 1 {
+			shuffle(idx[lo:hi], r)
+			ties++
+		}
+	})
+	return idx, ties
+}
+
+// rankMax returns the ranks of `a`, where tied values all receive the maximum rank of their block.
+func rankMax[T cmp.Ordered](a []T) []float64 {
+	res, _, _ := rankMaxes(a)
+	return res
+}
+
+// rankMaxes returns the max-ranks of both `a` and `-a` using a single sort; that is, the number of values that are
+// less than or equal to each value, and the number of values that are greater than or equal to each value.
+// It also returns the number of groups of tied values.
+func rankMaxes[T cmp.Ordered](a []T) ([]float64, []float64, int) {
+	idx := sortedIndex(a)
+	asc := make([]float64, len(a))
+	desc := make([]float64, len(a))
+	var ties int
+	tieBlocks(a, idx, func(lo, hi int) {
+		for _, i := range idx[lo:hi] {
+			asc[i] = float64(hi)
+			desc[i] = float64(len(a) - lo)
+		}
+		if hi-lo > 1 {
+			ties++
+		}
+	})
+	return asc, desc, ties
+}
+
+// ranks combines argsort and rankMaxes on a single sort of `a`, for a variable which plays the role of both X and Y.
+func ranks[T cmp.Ordered](a []T, r *rand.Rand) ([]int, []float64, []float64, int) {
+	idx := sortedIndex(a)
+	asc := make([]float64, len(a))
+	desc := make([]float64, len(a))
+	var ties int
+	tieBlocks(a, idx, func(lo, hi int) {
+		for _, i := range idx[lo:hi] {
+			asc[i] = float64(hi)
+			desc[i] = float64(len(a) - lo)
+		}
+		if hi-lo > 1 {
+			shuffle(idx[lo:hi], r)
+			ties++
+		}
+	})
+	return idx, asc, desc, ties
+}
+
+func shuffle(a []int, r *rand.Rand) {
+	r.Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
+}
@@ -0,0 +1,131 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArgsortRankMax(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().Unix()))
+	input := []float64{0., 2., 3., 2.}
+
+	// The tied values take either order
+	want1 := []int{0, 1, 3, 2}
+	want2 := []int{0, 3, 1, 2}
+
+	idx, _ := argsort(input, r)
+	if !reflect.DeepEqual(idx, want1) &&
+		!reflect.DeepEqual(idx, want2) {
+		t.Errorf("wrong result for argsort with input:%v, got:%v", input, idx)
+	}
+
+	got := rankMax(input)
+	want := []float64{1., 3., 4., 3.}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result for rankMax with input:%v, got:%v, want:%v", input, got, want)
+	}
+}
+
+func TestArgsort(t *testing.T) {
+	input := []float64{3., 1., 2.}
+
+	got, ties := argsort(input, rand.New(rand.NewSource(1)))
+	want := []int{1, 2, 0}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result for argsort with input:%v, got:%v, want:%v", input, got, want)
+	}
+	if ties != 0 {
+		t.Errorf("wrong number of tie groups for argsort with input:%v, got:%v, want:%v", input, ties, 0)
+	}
+}
+
+func TestArgsortWithTies(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().Unix()))
+	input := []float64{5, 10, 2, 99, 5, 2, 8, 17, 5}
+	got, ties := argsort(input, r)
+	if ties != 2 {
+		t.Errorf("wrong number of tie groups for argsort with input:%v, got:%v, want:%v", input, ties, 2)
+	}
+
+	// [2 5 0 8 4 6 1 7 3]
+	// [5 2 0 8 4 6 1 7 3]
+	// [2 5 4 0 8 6 1 7 3]
+	// [2 5 0 8 4 6 1 7 3]
+	// [2 5 4 8 0 6 1 7 3]
+	// [5 2 8 0 4 6 1 7 3]
+	// ties (i.e. indexes {2, 5} and {0, 4, 8}) should be shuffled
+
+	tie1 := got[0:2]
+	tie2 := got[2:5]
+	rest := got[5:]
+	if !(contains(tie1, 2) && contains(tie1, 5)) {
+		t.Error("first tie block should contain 2 and 5")
+	}
+
+	if !(contains(tie2, 0) && contains(tie2, 4) && contains(tie2, 8)) {
+		t.Error("second tie should contain 0, 4 and 8")
+	}
+
+	wantRest := []int{6, 1, 7, 3}
+	if !reflect.DeepEqual(rest, wantRest) {
+		t.Error("rest of the argsort result should be [1 7 3]")
+	}
+}
+
+func TestRankMaxes(t *testing.T) {
+	input := []float64{math.NaN(), 3, math.Inf(1), 0, math.Copysign(0, -1), 3, math.Inf(-1), math.NaN()}
+
+	gotAsc, gotDesc, gotTies := rankMaxes(input)
+	wantAsc := []float64{8, 5, 6, 3, 3, 5, 1, 8}
+	wantDesc := []float64{2, 5, 3, 7, 7, 5, 8, 2}
+	if !reflect.DeepEqual(gotAsc, wantAsc) {
+		t.Errorf("wrong ascending ranks for rankMaxes with input:%v, got:%v, want:%v", input, gotAsc, wantAsc)
+	}
+	if !reflect.DeepEqual(gotDesc, wantDesc) {
+		t.Errorf("wrong descending ranks for rankMaxes with input:%v, got:%v, want:%v", input, gotDesc, wantDesc)
+	}
+	if gotTies != 3 {
+		t.Errorf("wrong number of tie groups for rankMaxes with input:%v, got:%v, want:%v", input, gotTies, 3)
+	}
+}
+
+func TestArgsortTrailingTies(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().Unix()))
+	input := []float64{1, 7, 7, 7}
+
+	// The last block of ties must be shuffled too; over enough draws every index should lead its block
+	seen := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		got, _ := argsort(input, r)
+		if got[0] != 0 {
+			t.Fatalf("wrong result for argsort with input:%v, got:%v", input, got)
+		}
+		seen[got[1]] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("trailing ties of %v were not broken at random, saw %v", input, seen)
+	}
+
+	// The input must not be modified
+	if !reflect.DeepEqual(input, []float64{1, 7, 7, 7}) {
+		t.Errorf("argsort modified its input: %v", input)
+	}
+}
+
+func BenchmarkRankTies(b *testing.B) {
+	b.ReportAllocs()
+	x := make([]float64, 1_000_000)
+	for i := range x {
+		x[i] = float64(rand.Intn(5))
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		argsort(x, r)
+		rankMaxes(x)
+	}
+}
@@ -0,0 +1,122 @@
+package xicor
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"math/rand"
+)
+
+// symmetric holds both directions of a symmetric calculation; xy measures how much Y is a function of X, and yx the other way around.
+type symmetric struct {
+	xy, yx     stats
+	ordX, ordY []int
+}
+
+// symmetricCorrelation calculates both directions of the correlation coefficient for the cleaned data vectors x and y, ranking each vector only once.
+// Each point is compared to its `m` right neighbours.
+func symmetricCorrelation[X, Y cmp.Ordered](x []X, y []Y, m int, r *rand.Rand) symmetric {
+	ordX, fx, gx, tiesX := ranks(x, r)
+	ordY, fy, gy, tiesY := ranks(y, r)
+
+	sym := symmetric{
+		xy:   fromRanks(fy, gy, tiesY),
+		yx:   fromRanks(fx, gx, tiesX),
+		ordX: ordX,
+		ordY: ordY,
+	}
+	sym.xy.tiesX = tiesX
+	sym.yx.tiesX = tiesY
+	sym.xy.m = m
+	sym.yx.m = m
+	sym.xy.xi = sym.xy.walk(ordX)
+	sym.yx.xi = sym.yx.walk(ordY)
+	return sym
+}
+
+// xi returns the symmetric correlation coefficient.
+func (sym symmetric) xi() float64 {
+	return math.Max(sym.xy.xi, sym.yx.xi)
+}
+
+// max returns the direction which attains the symmetric coefficient, along with the order in which it walks the max-ranks.
+func (sym symmetric) max() (stats, []int) {
+	if sym.yx.xi > sym.xy.xi {
+		return sym.yx, sym.ordY
+	}
+	return sym.xy, sym.ordX
+}
+
+// tolerance returns the distance below which two symmetric coefficients are taken to be equal, which is the smaller of those of the two directions.
+func (sym symmetric) tolerance() float64 {
+	return math.Min(sym.xy.tolerance(), sym.yx.tolerance())
+}
+
+// testSymmetric fills in the p-value of the symmetric coefficient in `res`, along with the related fields.
+// The standard deviation and test statistic refer to the direction which attains the maximum.
+func (d *Xi) testSymmetric(ctx context.Context, res *Result, sym symmetric, seed int64) error {
+	n := float64(len(sym.ordX) * sym.xy.m)
+	res.Method = d.Method
+
+	if !d.DataTies || d.Method == MethodAsymptotic {
+		vxy, vyx := 2./5., 2./5.
+		if d.DataTies {
+			vxy = asymptoticVariance(sym.xy.f, sym.xy.cval)
+			vyx = asymptoticVariance(sym.yx.f, sym.yx.cval)
+		}
+
+		// Under independence, sqrt(n)*xi(X, Y) and sqrt(n)*xi(Y, X) are asymptotically independent normal variables,
+		// so their maximum stays below m with probability pnorm(sqrt(n)*m/sd1)*pnorm(sqrt(n)*m/sd2).
+		// For the revised coefficient, n stands for the number of pairs of neighbours n*m.
+		m := math.Sqrt(n) * res.Xi
+		res.Method = MethodAsymptotic
+		// The complement of the product is q1+q2*(1-q1) in terms of the upper tails, which keeps its accuracy when both are tiny,
+		// while the product itself is the p-value for a maximum smaller than under independence
+		q1, logq1 := upperTail(m / math.Sqrt(vxy))
+		q2, logq2 := upperTail(m / math.Sqrt(vyx))
+		p1, logp1 := upperTail(-m / math.Sqrt(vxy))
+		p2, logp2 := upperTail(-m / math.Sqrt(vyx))
+		res.alternative(d.Alternative, q1+q2*(1-q1), logAddExp(logq1, logq2+math.Log1p(-q1)), p1*p2, logp1+logp2)
+		v := vxy
+		if sym.yx.xi > sym.xy.xi {
+			v = vyx
+		}
+		res.SD = math.Sqrt(v / n)
+		res.Statistic = res.Xi / res.SD
+		return nil
+	}
+
+	rp, err := d.symmetricPermutations(ctx, sym, seed)
+	if err != nil {
+		return err
+	}
+	res.permutation(rp, d.Alternative, d.Exceedances, sym.tolerance())
+	return nil
+}
+
+// symmetricPermutations draws `Nperms` symmetric coefficients under independence, stopping early in the sequential test.
+// A permutation pairs x[perm[i]] with y[i]; walking in the order of the permuted x's visits the max-ranks of y in the order inv[ordX],
+// where inv is the inverse permutation, while walking in the order of the y's visits the max-ranks of x in the order perm[ordY].
+func (d *Xi) symmetricPermutations(ctx context.Context, sym symmetric, seed int64) ([]float64, error) {
+	n := len(sym.ordX)
+	return d.sample(ctx, d.Nperms, seed, func() func(r *rand.Rand) float64 {
+		perm := make([]int, n)
+		inv := make([]int, n)
+		fxy := make([]float64, n)
+		fyx := make([]float64, n)
+		return func(r *rand.Rand) float64 {
+			for i := range perm {
+				perm[i] = i
+			}
+			shuffle(perm, r)
+			for i, p := range perm {
+				inv[p] = i
+			}
+			for k := 0; k < n; k++ {
+				fxy[k] = sym.xy.f[inv[sym.ordX[k]]]
+				fyx[k] = sym.yx.f[perm[sym.ordY[k]]]
+			}
+			return math.Max(sym.xy.permuted(fxy), sym.yx.permuted(fyx))
+		}
+	}, d.stopRule(sym.xi(), sym.tolerance()))
+}
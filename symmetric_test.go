@@ -0,0 +1,83 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSymmetric(t *testing.T) {
+	x := anscombesQuartet["x_1"]
+	y := anscombesQuartet["y_1"]
+
+	xy, _ := Compute(x, y)
+	yx, _ := Compute(y, x)
+	got, err := Compute(x, y, WithSymmetric())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEpsilon(t, got.Xi, math.Max(xy.Xi, yx.Xi))
+	if got.Pvalue < math.Min(xy.Pvalue, yx.Pvalue) {
+		t.Errorf("the symmetric p-value should account for taking the maximum; got: %v, directions: %v and %v", got.Pvalue, xy.Pvalue, yx.Pvalue)
+	}
+	assertEpsilon(t, 1-got.Pvalue, pnorm(got.Xi/xy.SD)*pnorm(got.Xi/yx.SD))
+
+	got, err = Compute(x, y, WithSymmetric(), WithoutTies())
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := math.Sqrt(float64(len(x))) * got.Xi / math.Sqrt(2./5.)
+	assertEpsilon(t, got.Pvalue, 1-pnorm(z)*pnorm(z))
+
+	// y = x^2 on a symmetric range is a function of x, but x isn't a function of y
+	x = []float64{-5, -4, -3, -2, -1, 0, 1, 2, 3, 4, 5}
+	y = make([]float64, len(x))
+	for i := range x {
+		y[i] = x[i] * x[i]
+	}
+	xy, _ = Compute(x, y)
+	got, _ = Compute(y, x, WithSymmetric(), WithSeed(1))
+	assertEpsilon(t, got.Xi, xy.Xi)
+
+	if _, _, err := Matrix([][]float64{x, y}, WithSymmetric()); err == nil {
+		t.Error("expected an error when asking for a symmetric matrix")
+	}
+}
+
+func TestSymmetricPermutation(t *testing.T) {
+	got1, err := Compute(xx, yy, WithSymmetric(), WithPermutationPvalue(500), WithSeed(6), WithWorkers(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got2, _ := Compute(xx, yy, WithSymmetric(), WithPermutationPvalue(500), WithSeed(6), WithWorkers(4))
+	if !reflect.DeepEqual(got1, got2) {
+		t.Errorf("symmetric permutation test depends on the number of workers; got: %+v and %+v", got1, got2)
+	}
+
+	asymptotic, _ := Compute(xx, yy, WithSymmetric(), WithSeed(6))
+	if abs(got1.Pvalue-asymptotic.Pvalue) > 0.06 {
+		t.Errorf("permutation and asymptotic p-values disagree; got: %v and %v", got1.Pvalue, asymptotic.Pvalue)
+	}
+}
+
+func TestSymmetricCalibration(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	x := make([]float64, 300)
+	y := make([]float64, 300)
+
+	var rejected int
+	for rep := 0; rep < 400; rep++ {
+		for i := range x {
+			x[i] = r.NormFloat64()
+			y[i] = float64(r.Intn(20))
+		}
+		res, _ := Compute(x, y, WithSymmetric(), WithRand(r))
+		if res.Pvalue < 0.05 {
+			rejected++
+		}
+	}
+	if rejected < 8 || rejected > 35 {
+		t.Errorf("the symmetric test rejected %v out of 400 independent samples at the 5%% level", rejected)
+	}
+}
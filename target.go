@@ -0,0 +1,202 @@
+package xicor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Target holds an outcome Y prepared for testing many predictors X against it.
+// The ranks and the denominator of xi only depend on Y, as do its asymptotic variance and permutation distribution under independence,
+// so they're calculated once by `NewTarget` and every X then only costs a sort of its own values.
+//
+// When the default missing-value policy drops pairs, an X containing NaNs changes the remaining Y, and is instead calculated from scratch like `Compute` would.
+type Target struct {
+	d Xi
+	y []float64
+	s stats
+	// pairwise is set when Y itself contains NaNs which are dropped along with the pairs, so that nothing can be prepared in advance.
+	pairwise bool
+}
+
+// Screened holds the outcome of testing a single predictor in `Target.Screen`.
+type Screened struct {
+	// Column is the index of the predictor among the screened columns.
+	Column int
+	Result Result
+}
+
+// NewTarget prepares y for testing many predictors against it, configured by the same functional options as `New`.
+// With the permutation test, the permutations are drawn here once and shared by all the predictors; a `NullDistribution` set with `WithNullDistribution` is used as is.
+// The symmetric coefficient isn't supported, as it also needs the predictors to play the role of Y.
+func NewTarget(y []float64, options ...func(*Xi)) (*Target, error) {
+	return NewTargetContext(context.Background(), y, options...)
+}
+
+// NewTargetContext is like `NewTarget`, but stops early and returns the context's error once `ctx` is done.
+func NewTargetContext(ctx context.Context, y []float64, options ...func(*Xi)) (*Target, error) {
+	d := New(nil, nil, options...)
+	if err := d.validate(d.WantPvalue); err != nil {
+		return nil, err
+	}
+	if d.Interval != "" {
+		if err := d.validateInterval(); err != nil {
+			return nil, err
+		}
+	}
+	if d.Symmetric {
+		return nil, errors.New("xicor: Target doesn't support the symmetric coefficient")
+	}
+	if _, _, err := clean(d, y, y); err != nil {
+		return nil, err
+	}
+
+	t := &Target{d: *d, y: y}
+	t.pairwise = (d.Missing == "" || d.Missing == MissingDrop) && hasNaN(y)
+	if t.pairwise {
+		return t, nil
+	}
+
+	t.s = prepare(y)
+	t.s.m = d.neighbours(len(y))
+	if !d.WantPvalue || !d.DataTies {
+		return t, nil
+	}
+	switch {
+	case d.Method == MethodAsymptotic:
+		t.s.v = asymptoticVariance(t.s.f, t.s.cval)
+	case d.Method == MethodPermutation && d.Null != nil:
+		if !d.Null.matches(t.s) {
+			return nil, errors.New("xicor: the null distribution was drawn for a Y with other ties or another number of observations")
+		}
+		t.s.rp = d.Null.Coefficients
+	case d.Method == MethodPermutation:
+		// Drawn from the same streams as those of `Compute`, so that seeded calculations give the same p-values
+		rp, err := d.permutations(ctx, t.s, d.streamSeed(d.rng()), nil)
+		if err != nil {
+			return nil, err
+		}
+		t.s.rp = rp
+	}
+	return t, nil
+}
+
+// Score calculates the correlation coefficient xi(x, Y), measuring how much the target is a function of x.
+func (t *Target) Score(x []float64) (float64, error) {
+	res, err := t.result(context.Background(), &t.d, x, true)
+	if err != nil {
+		return 0, err
+	}
+	return res.Xi, nil
+}
+
+// Test calculates the correlation coefficient xi(x, Y) along with its p-value unless `WantPvalue=false`, and its confidence interval when one is configured.
+// Like `Compute`, it's safe for concurrent use as long as a source supplied via `WithRand` isn't shared between goroutines; with the same options it gives the same result.
+func (t *Target) Test(x []float64) (Result, error) {
+	return t.TestContext(context.Background(), x)
+}
+
+// TestContext is like `Test`, but stops early and returns the context's error once `ctx` is done.
+func (t *Target) TestContext(ctx context.Context, x []float64) (Result, error) {
+	return t.result(ctx, &t.d, x, false)
+}
+
+// Screen tests every column of x against the target in parallel across `Workers` goroutines, and returns the `k` with the largest coefficients, sorted by decreasing xi;
+// a non-positive k returns all of them. Every column breaks its ties with its own stream, derived from a single seed, so the outcome doesn't depend on the number of workers.
+// `Progress` is called with the number of columns tested so far.
+func (t *Target) Screen(x [][]float64, k int) ([]Screened, error) {
+	return t.ScreenContext(context.Background(), x, k)
+}
+
+// ScreenContext is like `Screen`, but stops early and returns the context's error once `ctx` is done.
+func (t *Target) ScreenContext(ctx context.Context, x [][]float64, k int) ([]Screened, error) {
+	x, err := t.d.columns(x)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := t.d.streamSeed(t.d.rng())
+	screened := make([]Screened, len(x))
+	errs := make([]error, len(x))
+	var mu sync.Mutex
+	var done int
+	err = forEach(ctx, t.d.Workers, len(x), func(_, j int) {
+		d := t.d
+		d.seed, d.seeded = chunkSeed(seed, j), true
+		d.Workers = 1
+		// The columns which are calculated from scratch don't report their own draws
+		d.Progress = nil
+		screened[j].Column = j
+		screened[j].Result, errs[j] = t.result(ctx, &d, x[j], false)
+
+		if t.d.Progress != nil {
+			mu.Lock()
+			done++
+			t.d.Progress(done, len(x))
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Undefined coefficients, as those of a constant target, go last
+	sort.SliceStable(screened, func(i, j int) bool {
+		a, b := screened[i].Result.Xi, screened[j].Result.Xi
+		return a > b || (!math.IsNaN(a) && math.IsNaN(b))
+	})
+	if k > 0 && k < len(screened) {
+		screened = screened[:k]
+	}
+	return screened, nil
+}
+
+// result runs a calculation on x against the target with the configuration in `d`, reusing the quantities prepared from Y. Only xi is calculated when `score` is set.
+func (t *Target) result(ctx context.Context, d *Xi, xin []float64, score bool) (Result, error) {
+	pvalue := d.WantPvalue && !score
+	interval := d.Interval != "" && !score
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	x, y, err := clean(d, xin, t.y)
+	if err != nil {
+		return Result{}, err
+	}
+	if t.pairwise || len(x) != len(t.y) {
+		return compute(ctx, d, xin, t.y, pvalue, interval)
+	}
+
+	r := d.rng()
+	ord, tiesX := argsort(x, r)
+	s := t.s
+	s.tiesX = tiesX
+	s.xi = s.walk(ord)
+	res := Result{
+		Xi:         s.xi,
+		N:          len(x),
+		Neighbours: s.m,
+		TiesX:      s.tiesX,
+		TiesY:      s.tiesY,
+	}
+	seed := d.streamSeed(r)
+	if interval {
+		if err := confidence(ctx, d, &res, x, y, s, ord, seed); err != nil {
+			return Result{}, err
+		}
+	}
+	if !pvalue {
+		return res, nil
+	}
+
+	if err := d.test(ctx, &res, s, seed); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
@@ -0,0 +1,194 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTarget(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	y := make([]float64, 300)
+	for i := range y {
+		y[i] = float64(r.Intn(50))
+	}
+	x := make([]float64, len(y))
+	for i := range x {
+		x[i] = math.Round(y[i] + 20*r.NormFloat64())
+	}
+
+	// Testing against a target gives the same results as computing from scratch
+	for name, options := range map[string][]func(*Xi){
+		"asymptotic":  {WithSeed(1)},
+		"permutation": {WithPermutationPvalue(300), WithSeed(1)},
+		"sequential":  {WithSequentialPermutationPvalue(5, 1000), WithSeed(1)},
+		"revised":     {WithRevised(3), WithSeed(1)},
+		"two-sided":   {WithAlternative(AlternativeTwoSided), WithoutTies(), WithSeed(1)},
+		"wald":        {WithWaldInterval(), WithSeed(1)},
+		"bootstrap":   {WithBootstrap(IntervalPercentile, 50), WithPermutationPvalue(100), WithSeed(1)},
+	} {
+		target, err := NewTarget(y, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := Compute(x, y, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := target.Test(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: the target changes the result; got: %+v, want: %+v", name, got, want)
+		}
+		xi, err := target.Score(x)
+		if err != nil || xi != want.Xi {
+			t.Errorf("%s: wrong score; got: %v, want: %v, error: %v", name, xi, want.Xi, err)
+		}
+	}
+
+	// An X with missing values drops pairs of Y, and is calculated from scratch
+	target, _ := NewTarget(y, WithSeed(1))
+	x[7] = math.NaN()
+	want, _ := Compute(x, y, WithSeed(1))
+	got, err := target.Test(x)
+	if err != nil || !reflect.DeepEqual(got, want) || got.Dropped != 1 {
+		t.Errorf("wrong result with missing values; got: %+v, want: %+v, error: %v", got, want, err)
+	}
+
+	// So is every X when Y has missing values
+	y[3] = math.NaN()
+	target, _ = NewTarget(y, WithPermutationPvalue(100), WithSeed(1))
+	want, _ = Compute(x, y, WithPermutationPvalue(100), WithSeed(1))
+	got, err = target.Test(x)
+	if err != nil || !reflect.DeepEqual(got, want) || got.Dropped != 2 {
+		t.Errorf("wrong result with missing values in Y; got: %+v, want: %+v, error: %v", got, want, err)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	r := rand.New(rand.NewSource(8))
+	n, p := 200, 30
+	y := make([]float64, n)
+	for i := range y {
+		y[i] = r.NormFloat64()
+	}
+	columns := make([][]float64, p)
+	for j := range columns {
+		columns[j] = make([]float64, n)
+		for i := range y {
+			columns[j][i] = r.NormFloat64()
+		}
+	}
+	// Columns 4, 11 and 20 are noisy versions of Y of increasing strength
+	for k, j := range []int{4, 11, 20} {
+		for i := range y {
+			columns[j][i] = y[i] + float64(3-k)*0.5*r.NormFloat64()
+		}
+	}
+
+	target, err := NewTarget(y, WithPermutationPvalue(200), WithSeed(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := target.Screen(columns, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 3 || top[0].Column != 20 || top[1].Column != 11 || top[2].Column != 4 {
+		t.Fatalf("wrong top predictors: %+v", top)
+	}
+	for _, s := range top {
+		if s.Result.Pvalue != 1./201. {
+			t.Errorf("expected column %v to be significant, got p-value: %v", s.Column, s.Result.Pvalue)
+		}
+	}
+
+	// The results are sorted, come with p-values, and don't depend on the number of workers
+	var want []Screened
+	for _, workers := range []int{1, 3, 8} {
+		target, _ := NewTarget(y, WithPermutationPvalue(200), WithSeed(4), WithWorkers(workers))
+		got, err := target.Screen(columns, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != p {
+			t.Fatalf("expected all the columns, got: %v", len(got))
+		}
+		for i := 1; i < p; i++ {
+			if got[i].Result.Xi > got[i-1].Result.Xi || got[i].Result.Pvalue == 0 {
+				t.Errorf("unsorted or missing results at %v: %+v", i, got[i])
+			}
+		}
+		if workers == 1 {
+			want = got
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("screening depends on the number of workers")
+		}
+	}
+
+	// Row-major predictors are transposed first
+	rows := make([][]float64, n)
+	for i := range rows {
+		rows[i] = make([]float64, p)
+		for j := range columns {
+			rows[i][j] = columns[j][i]
+		}
+	}
+	target, _ = NewTarget(y, WithPermutationPvalue(200), WithSeed(4), WithRowMajor())
+	got, err := target.Screen(rows, 3)
+	if err != nil || !reflect.DeepEqual(got, top) {
+		t.Errorf("wrong screening of row-major predictors; got: %+v, want: %+v, error: %v", got, top, err)
+	}
+}
+
+func TestScreenProgress(t *testing.T) {
+	columns := matrixColumns(100)
+	columns[2][5] = math.NaN()
+	target, err := NewTarget(columns[1], WithPermutationPvalue(300), WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The columns are reported as they're tested, even when the ones with NaNs are calculated from scratch in parallel
+	var calls, last int
+	target.d.Progress = func(done, total int) {
+		if done != last+1 || total != len(columns) {
+			t.Errorf("unexpected progress report: %v out of %v after %v", done, total, last)
+		}
+		calls++
+		last = done
+	}
+	if _, err := target.Screen(columns, 1); err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(columns) || last != len(columns) {
+		t.Errorf("wrong progress reports; got %v calls ending at %v", calls, last)
+	}
+}
+
+func TestTargetErrors(t *testing.T) {
+	y := []float64{1, 2, 3, 4, 5}
+	if _, err := NewTarget(y, WithSymmetric()); err == nil || err.Error() != "xicor: Target doesn't support the symmetric coefficient" {
+		t.Errorf("didn't receive the correct error: %v", err)
+	}
+	if _, err := NewTarget([]float64{1, math.NaN()}, WithMissing(MissingError)); err == nil || err.Error() != "xicor: input vectors contain NaN values" {
+		t.Errorf("didn't receive the correct error: %v", err)
+	}
+	null, _ := NewNullDistribution([]float64{1, 1, 2, 3, 4}, WithPermutationPvalue(10))
+	if _, err := NewTarget(y, WithNullDistribution(null)); err == nil || err.Error() != "xicor: the null distribution was drawn for a Y with other ties or another number of observations" {
+		t.Errorf("didn't receive the correct error: %v", err)
+	}
+
+	target, _ := NewTarget(y)
+	if _, err := target.Test([]float64{1, 2, 3}); err == nil || err.Error() != "xicor: mismatched size of input vectors" {
+		t.Errorf("didn't receive the correct error: %v", err)
+	}
+	if _, err := target.Screen([][]float64{{1, 2, 3, 4, 5}, {1, 2}}, 1); err == nil || err.Error() != "xicor: mismatched size of input vectors" {
+		t.Errorf("didn't receive the correct error: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package xicor
+
+import "math"
+
+// variance estimates the variance of xi when the x's order the observations as in `ord`. Unlike the asymptotic variance of the independence test,
+// it stays consistent when Y depends on X, so it can be used for confidence intervals; it takes linear time after ranking.
+//
+// xi is 1-A/C, where the numerator A sums the distances between the max-ranks of neighbouring points, and the denominator C is a V-statistic of Y alone.
+// Each distance is itself a count of the observations whose Y falls between those of the two neighbours, so A has a local part, the distances between the neighbours,
+// and a global part, how many pairs of neighbours each observation falls between. Linearizing xi gives every position k in the order of X a contribution psi[k]
+// which only depends on the points from k to k+m, so the contributions at lags beyond m are only correlated through the slowly varying conditional distribution of Y.
+// The variance of their sum is then the sum of the autocovariances up to lag m, less the part of the lag m+1 autocovariance that they all share.
+func (s stats) variance(ord []int) float64 {
+	n := len(ord)
+	nf := float64(n)
+
+	// Integer max-ranks of y, and the number of observations strictly below each rank
+	r := make([]int, n)
+	present := make([]bool, n+1)
+	for i, v := range s.f {
+		r[i] = int(math.Round(v * nf))
+		present[r[i]] = true
+	}
+	below := make([]int, n+1)
+	for k, last := 1, 0; k <= n; k++ {
+		below[k] = last
+		if present[k] {
+			last = k
+		}
+	}
+
+	// The local part of the numerator, and how many pairs of neighbours each rank falls between
+	psi := make([]float64, n)
+	cover := make([]float64, n+2)
+	var A1 float64
+	for k := range ord {
+		for l := 1; l <= s.m && k+l < n; l++ {
+			a, b := r[ord[k]], r[ord[k+l]]
+			if a > b {
+				a, b = b, a
+			}
+			psi[k] += float64(b-a) / nf
+			cover[a+1]++
+			cover[b+1]--
+		}
+		A1 += psi[k]
+	}
+	for k := 1; k < len(cover); k++ {
+		cover[k] += cover[k-1]
+	}
+	pairs := float64(s.m*n - s.m*(s.m+1)/2)
+	kappa := (nf - 1) / (2 * nf * pairs)
+	A := kappa * A1
+	C := s.cval
+
+	// The three terms of the projection of C, accumulated over the ranks of y
+	low := make([]float64, n+1)
+	high := make([]float64, n+1)
+	var total float64
+	for _, ri := range r {
+		g := (nf - float64(below[ri])) / nf
+		low[ri] += 1 - g
+		high[ri] += g
+		total += g
+	}
+	for k := 1; k <= n; k++ {
+		low[k] += low[k-1]
+		high[k] += high[k-1]
+	}
+
+	for k, i := range ord {
+		g := (nf - float64(below[r[i]])) / nf
+		psiC := (g*(1-g) + low[r[i]]/nf + (total-high[r[i]])/nf) / nf
+		psiA := kappa * (psi[k] + cover[r[i]]/nf)
+		psi[k] = -psiA/C + A/(C*C)*psiC
+	}
+
+	avg := mean(psi)
+	gamma := make([]float64, s.m+2)
+	for l := range gamma {
+		for k := 0; k+l < n; k++ {
+			gamma[l] += (psi[k] - avg) * (psi[k+l] - avg)
+		}
+		gamma[l] = gamma[l] / nf
+	}
+	v := gamma[0] - 2*float64(s.m)*gamma[s.m+1]
+	for l := 1; l <= s.m; l++ {
+		v += 2 * gamma[l]
+	}
+	return math.Max(v*nf, 0)
+}
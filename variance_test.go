@@ -0,0 +1,76 @@
+package xicor
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestVariance(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	tcs := []struct {
+		name string
+		m    int
+		y    func(x float64) float64
+	}{
+		{"independent", 1, func(float64) float64 { return r.NormFloat64() }},
+		{"linear", 1, func(x float64) float64 { return x + 0.7*r.NormFloat64() }},
+		{"smooth", 1, func(x float64) float64 { return math.Sin(3*x) + 0.3*r.NormFloat64() }},
+		{"revised", 3, func(x float64) float64 { return math.Sin(3*x) + 0.3*r.NormFloat64() }},
+		{"ties", 1, func(x float64) float64 { return math.Round(x + 0.7*r.NormFloat64()) }},
+	}
+	for _, tc := range tcs {
+		// Compare the estimated variance against the spread of xi over repeated samples
+		n := 500
+		var xis, vs []float64
+		for k := 0; k < 300; k++ {
+			x := make([]float64, n)
+			y := make([]float64, n)
+			for i := range x {
+				x[i] = r.NormFloat64()
+				y[i] = tc.y(x[i])
+			}
+			s, ord := correlation(x, y, tc.m, r)
+			xis = append(xis, s.xi)
+			vs = append(vs, s.variance(ord))
+		}
+		want := sd(xis) * sd(xis)
+		if got := mean(vs); math.Abs(got/want-1) > 0.2 {
+			t.Errorf("%s: estimated variance is too far from the observed one; got: %v, want: %v", tc.name, got, want)
+		}
+	}
+}
+
+func TestWaldInterval(t *testing.T) {
+	res, err := Compute(xx, yy, WithWaldInterval())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StdErr <= 0 {
+		t.Fatalf("expected a positive standard error, got: %v", res.StdErr)
+	}
+	assertEpsilon(t, res.ConfidenceInterval[0], res.Xi-1.959963984540054*res.StdErr)
+	assertEpsilon(t, res.ConfidenceInterval[1], res.Xi+1.959963984540054*res.StdErr)
+
+	// The Wald interval should be close to the bootstrap one
+	boot, _ := Compute(xx, yy, WithBootstrap(IntervalPercentile, 500), WithSeed(3))
+	if w := (res.ConfidenceInterval[1] - res.ConfidenceInterval[0]) / (boot.ConfidenceInterval[1] - boot.ConfidenceInterval[0]); w < 0.7 || w > 1.3 {
+		t.Errorf("unexpected width of the Wald interval relative to the bootstrap one: %v", w)
+	}
+
+	narrow, _ := Compute(xx, yy, WithWaldInterval(), WithConfidenceLevel(0.5))
+	assertEpsilon(t, narrow.ConfidenceInterval[1]-narrow.Xi, 0.6744897501960817*res.StdErr)
+
+	// The symmetric coefficient takes the standard error of the direction which attains it
+	sym, err := Compute(xx, yy, WithSymmetric(), WithWaldInterval(), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xy, _ := Compute(xx, yy, WithWaldInterval(), WithSeed(1))
+	yx, _ := Compute(yy, xx, WithWaldInterval(), WithSeed(1))
+	want := xy.StdErr
+	if yx.Xi > xy.Xi {
+		want = yx.StdErr
+	}
+	assertEpsilon(t, sym.StdErr, want)
+}
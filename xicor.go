@@ -1,7 +1,10 @@
 package xicor
 
 import (
+	"cmp"
+	"context"
 	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 	"sort"
@@ -10,17 +13,47 @@ import (
 // Xi is the main struct exposed by the `xicor` package.
 // It is used to receive the input data as well as configure some options on how to calculate the correlation coefficient and p-values.
 // In general, it is safe to just supply x and y, and leave other parameters to their default values.
+// An `Xi` keeps no state between calculations, so its methods can be called concurrently; see `Compute` for the details.
 type Xi struct {
-	X, Y       []float64
-	WantPvalue bool
-	Nperms     int
-	Method     string
-	DataTies   bool
-
-	// variables reused for p-values calculation
-	n    float64
-	f    []float64
-	cval float64
+	X, Y        []float64
+	WantPvalue  bool
+	Nperms      int
+	Exceedances int
+	Method      string
+	DataTies    bool
+	Missing     string
+	Workers     int
+	Symmetric   bool
+	Standardize bool
+	MaxFeatures int
+	RowMajor    bool
+	Revised     bool
+	Neighbours  int
+	Alternative string
+	Interval    string
+	Nboot       int
+	Level       float64
+
+	// Distance, when set, replaces the Euclidean distance in the nearest neighbour searches of multivariate predictors.
+	Distance func(a, b []float64) float64
+
+	// Null, when set, holds the coefficients which the permutation test reuses rather than drawing its own; see `NullDistribution`.
+	Null *NullDistribution
+
+	// Progress, when set, is called by the permutation test and the bootstrap with the number of draws completed so far; `Matrix` and `Target.Screen` report the number of rows or columns completed instead.
+	// It may be called from different goroutines, but never concurrently.
+	Progress func(done, total int)
+
+	// Rand is the source of randomness used to break ties in X and to draw the permutations of the permutation test.
+	// When nil, every computation uses a private source seeded from the global `math/rand` source.
+	Rand *rand.Rand
+
+	seed   int64
+	seeded bool
+	// withNull is set by `WithNullDistribution`, so that a nil null distribution is reported rather than ignored.
+	withNull bool
+	// sequential is set by `WithSequentialPermutationPvalue`, so that a non-positive number of exceedances is reported rather than running a fixed-count test.
+	sequential bool
 }
 
 // MethodAsymptotic employs the 'asympotic theory' to calculate the p-value.
@@ -29,15 +62,55 @@ var MethodAsymptotic = "asymptotic"
 // MethodPermutation employes `NPerms` permutations to estimate the p-value. As per Dr. Sourav, "usually there is no need for the permutation test, the asymptotic theory is good enough".
 var MethodPermutation = "permutation"
 
+// MethodExact calculates the p-value from the exact distribution of xi over all the permutations of the data, which is only available for small samples;
+// it is meant for the sample sizes where the asymptotic theory is a poor approximation, and the permutation test a noisy one.
+var MethodExact = "exact"
+
+// MissingDrop removes every pair where either X or Y is NaN before calculating the correlation. This is the default missing-value policy.
+var MissingDrop = "drop"
+
+// MissingError makes the calculation fail if either X or Y contains a NaN.
+var MissingError = "error"
+
+// MissingLast keeps NaNs in the data, treating them as tied with each other and larger than any other value.
+var MissingLast = "last"
+
+// AlternativeGreater tests whether xi is larger than under independence, that is whether Y depends on X. This is the default alternative hypothesis.
+var AlternativeGreater = "greater"
+
+// AlternativeLess tests whether xi is smaller than under independence, which can flag artifacts such as structured designs or unusual patterns of ties.
+var AlternativeLess = "less"
+
+// AlternativeTwoSided tests whether xi differs from its distribution under independence in either direction; the p-value is twice the smaller one-sided p-value, capped at 1.
+var AlternativeTwoSided = "two-sided"
+
+// IntervalPercentile uses the quantiles of the bootstrapped coefficients as the confidence interval.
+var IntervalPercentile = "percentile"
+
+// IntervalBasic reflects the quantiles of the bootstrapped coefficients around xi, which corrects for the bias of the bootstrap distribution.
+var IntervalBasic = "basic"
+
+// IntervalBCa adjusts the quantiles of the bootstrapped coefficients for both their bias and skewness; it takes a further n calculations of xi, leaving out one pair at a time.
+var IntervalBCa = "bca"
+
+// IntervalWald uses xi plus or minus a multiple of its standard error as the confidence interval, estimating the variance of xi without resampling; this suits large samples,
+// where the bootstrap would be too expensive.
+var IntervalWald = "wald"
+
 // New creates a `Xi` object which can be used to calculate the correlation coefficient along with the p-value. It receives the input datasets, as well as a number of functional options to configure the runtime behavior.
 func New(x, y []float64, options ...func(*Xi)) *Xi {
 	res := &Xi{
-		X:          x,
-		Y:          y,
-		WantPvalue: true,
-		Nperms:     1000,
-		Method:     "asymptotic",
-		DataTies:   true,
+		X:           x,
+		Y:           y,
+		WantPvalue:  true,
+		Nperms:      1000,
+		Method:      "asymptotic",
+		DataTies:    true,
+		Missing:     MissingDrop,
+		Alternative: AlternativeGreater,
+		Standardize: true,
+		Nboot:       1000,
+		Level:       0.95,
 	}
 
 	for _, o := range options {
@@ -56,6 +129,7 @@ func WithAsymptoticPvalue() func(*Xi) {
 }
 
 // WithPermutationPvalue makes sure that the p-value will be estimated using `Nperms` permutations.
+// The observed coefficient counts as one of them, so that the p-value is at least 1/(Nperms+1); the result reports its Monte Carlo standard error.
 func WithPermutationPvalue(nperms int) func(*Xi) {
 	return func(d *Xi) {
 		d.WantPvalue = true
@@ -64,6 +138,56 @@ func WithPermutationPvalue(nperms int) func(*Xi) {
 	}
 }
 
+// WithBootstrap makes `Compute` report a confidence interval for xi, using `nboot` bootstrap resamples of the (X, Y) pairs; use one of `IntervalPercentile`, `IntervalBasic` or `IntervalBCa`.
+func WithBootstrap(interval string, nboot int) func(*Xi) {
+	return func(d *Xi) {
+		d.Interval = interval
+		d.Nboot = nboot
+	}
+}
+
+// WithWaldInterval makes `Compute` report a Wald confidence interval for xi, along with its standard error, both of which remain valid when Y depends on X.
+func WithWaldInterval() func(*Xi) {
+	return func(d *Xi) {
+		d.Interval = IntervalWald
+	}
+}
+
+// WithConfidenceLevel sets the confidence level of the intervals reported by `Compute`; by default it is 0.95.
+func WithConfidenceLevel(level float64) func(*Xi) {
+	return func(d *Xi) {
+		d.Level = level
+	}
+}
+
+// WithExactPvalue makes sure that the p-value will be calculated from the exact permutation distribution of xi, which is available for up to 60 observations.
+func WithExactPvalue() func(*Xi) {
+	return func(d *Xi) {
+		d.WantPvalue = true
+		d.Method = MethodExact
+	}
+}
+
+// WithAlternative sets the alternative hypothesis of the independence test; use one of `AlternativeGreater`, `AlternativeLess` or `AlternativeTwoSided`.
+func WithAlternative(alternative string) func(*Xi) {
+	return func(d *Xi) {
+		d.Alternative = alternative
+	}
+}
+
+// WithSequentialPermutationPvalue makes the p-value be estimated by the sequential permutation test of Besag and Clifford, which stops as soon as `h` permuted coefficients
+// are at least as extreme as the observed one, or after `maxPerms` permutations. Clearly null results stop after a few permutations, while significant ones use the whole budget;
+// the result reports the number of permutations actually drawn, along with the Monte Carlo standard error of the p-value.
+func WithSequentialPermutationPvalue(h, maxPerms int) func(*Xi) {
+	return func(d *Xi) {
+		d.WantPvalue = true
+		d.Method = MethodPermutation
+		d.Nperms = maxPerms
+		d.Exceedances = h
+		d.sequential = true
+	}
+}
+
 // WithoutTies informs the algorithm that there are no ties in the data, and uses some simpler theory to calculate the p-value. There is no harm in leaving DataTies to `true` even if there are no ties.
 func WithoutTies() func(*Xi) {
 	return func(d *Xi) {
@@ -72,276 +196,608 @@ func WithoutTies() func(*Xi) {
 	}
 }
 
-// Correlation calculates and returns the correlation coefficient for the input data vectors `X` and `Y` along with an error.
-func (d *Xi) Correlation() (float64, error) {
-	if len(d.X) != len(d.Y) {
-		return 0, errors.New("xicor: mismatched size of input vectors")
+// WithSymmetric makes the calculation use the symmetric coefficient max(xi(X, Y), xi(Y, X)), which measures whether either variable is a function of the other, instead of only Y being a function of X.
+func WithSymmetric() func(*Xi) {
+	return func(d *Xi) {
+		d.Symmetric = true
 	}
+}
 
-	// x, y are the data vectors
-	// Find and Remove N/A pair values
-	removeNaNs(d.X, d.Y)
+// WithRevised makes the calculation use the revised coefficient of Lin and Han (Biometrika, 2023), which compares each point to its `m` nearest right neighbours in the order of X instead of only the next one.
+// Averaging over more neighbours gives a more powerful test of independence, especially against smooth alternatives, at the cost of a coefficient which no longer reaches 1 for every noiseless function.
+// When `m` is not positive, it grows with the sample size as ⌊n^(1/5)⌋; `m=1` gives the original coefficient.
+func WithRevised(m int) func(*Xi) {
+	return func(d *Xi) {
+		d.Revised = true
+		d.Neighbours = m
+	}
+}
 
-	// Factor variables should be converted to integers here
-	// https://www.rdocumentation.org/packages/base/versions/3.6.2/topics/factor
-	//	if (factor == T) {
-	//	if (!is.numeric(x)) x = as.numeric(factor(x))
-	//	if (!is.numeric(y)) y = as.numeric(factor(y))
-	//}
+// WithoutStandardization makes the nearest neighbour searches of multivariate predictors use the columns as they are, instead of standardizing them first.
+func WithoutStandardization() func(*Xi) {
+	return func(d *Xi) {
+		d.Standardize = false
+	}
+}
 
-	// Sample Size
-	d.n = float64(len(d.X))
+// WithRowMajor makes the multivariate predictors be read row by row, so that x[i] holds the vector of observation i, instead of column by column.
+func WithRowMajor() func(*Xi) {
+	return func(d *Xi) {
+		d.RowMajor = true
+	}
+}
 
-	// PI is the rank vector for x, with ties broken at random
-	pi := rankRND(d.X)
+// WithDistance makes the nearest neighbour searches of multivariate predictors use `dist` instead of the Euclidean distance.
+func WithDistance(dist func(a, b []float64) float64) func(*Xi) {
+	return func(d *Xi) {
+		d.Distance = dist
+	}
+}
 
-	// f[i] is number of j s.t. y[j] <= y[i], divided by n.
-	d.f = rankMax(d.Y)
-	for i := range d.f {
-		d.f[i] = d.f[i] / d.n
+// WithMaxFeatures caps the number of features selected by `SelectFeatures`.
+func WithMaxFeatures(k int) func(*Xi) {
+	return func(d *Xi) {
+		d.MaxFeatures = k
 	}
+}
 
-	// g[i] is number of j s.t. y[j] >= y[i], divided by n.
-	ym := make([]float64, len(d.Y))
-	for i := range d.Y {
-		ym[i] = -d.Y[i]
+// WithWorkers sets the number of goroutines used by the permutation test and the other parallel calculations; by default it uses `runtime.GOMAXPROCS`. The results don't depend on the number of workers.
+func WithWorkers(workers int) func(*Xi) {
+	return func(d *Xi) {
+		d.Workers = workers
 	}
-	g := rankMax(ym)
-	for i := range g {
-		g[i] = g[i] / d.n
+}
+
+// WithProgress registers a callback which receives the progress of the permutation test as the number of completed permutations out of the total.
+func WithProgress(fn func(done, total int)) func(*Xi) {
+	return func(d *Xi) {
+		d.Progress = fn
 	}
+}
 
-	// order of the x's, ties broken at random.
-	ord := argsort(pi)
+// WithMissing sets the policy for handling NaN values in the input; use one of `MissingDrop`, `MissingError` or `MissingLast`.
+func WithMissing(policy string) func(*Xi) {
+	return func(d *Xi) {
+		d.Missing = policy
+	}
+}
 
-	// Rearrange f according to ord.
-	ford := make([]float64, len(d.f))
-	for i, val := range ord {
-		_ = val
-		ford[i] = d.f[ord[i]]
+// WithRand makes every random tie-break and permutation draw use `r` instead of the global `math/rand` source.
+// A `*rand.Rand` is not safe for concurrent use, so `r` should not be shared between concurrent computations.
+func WithRand(r *rand.Rand) func(*Xi) {
+	return func(d *Xi) {
+		d.Rand = r
+		d.seeded = false
 	}
+}
 
-	// xi is calculated in the next lines
-	diffs := make([]float64, 0)
-	for i := 0; i < int(d.n)-1; i++ {
-		diffs = append(diffs, abs(ford[i]-ford[i+1]))
+// WithSeed makes the computation deterministic; every calculation starts from a fresh source seeded with `seed`, so two identically configured `Xi` objects always return identical results.
+func WithSeed(seed int64) func(*Xi) {
+	return func(d *Xi) {
+		d.seed = seed
+		d.seeded = true
 	}
-	A1 := mean(diffs) * (d.n - 1) / (2 * d.n)
+}
 
-	muls := make([]float64, 0)
-	for _, val := range g {
-		muls = append(muls, val*(1-val))
+// rng returns the source of randomness to be used for a single calculation.
+func (d *Xi) rng() *rand.Rand {
+	if d.seeded {
+		return rand.New(rand.NewSource(d.seed))
 	}
-	d.cval = mean(muls)
+	if d.Rand != nil {
+		return d.Rand
+	}
+	return rand.New(rand.NewSource(rand.Int63()))
+}
 
-	xi := 1 - A1/d.cval
+// streamSeed returns the seed from which the independent random streams of a calculation, such as those of the permutation test, are derived.
+// Seeded calculations derive it from the seed alone, so the streams don't depend on the draws used to break ties in X.
+func (d *Xi) streamSeed(r *rand.Rand) int64 {
+	if d.seeded {
+		return chunkSeed(d.seed, -1)
+	}
+	return r.Int63()
+}
 
-	return xi, nil
+// neighbours returns the number of right neighbours each of the `n` points is compared to.
+func (d *Xi) neighbours(n int) int {
+	m := 1
+	if d.Revised {
+		m = d.Neighbours
+		if m <= 0 {
+			// The floating-point root can fall just short of an exact fifth power, so the floor is corrected in integers
+			m = int(math.Pow(float64(n), 1./5.))
+			pow5 := func(k int) int { return k * k * k * k * k }
+			for pow5(m+1) <= n {
+				m++
+			}
+			for m > 1 && pow5(m) > n {
+				m--
+			}
+		}
+	}
+	if m > n-1 {
+		m = n - 1
+	}
+	if m < 1 {
+		m = 1
+	}
+	return m
 }
 
-// Pvalue calculates and returns the correlation coefficient and p-value for the input data vectors `X` and `Y` along with an error.
-func (d *Xi) Pvalue() (float64, float64, error) {
-	xi, err := d.Correlation()
-	if err != nil {
-		return 0, 0, err
+// Dropped returns the number of (X, Y) pairs which are removed from the calculation by the missing-value policy.
+func (d *Xi) Dropped() int {
+	if d.Missing != "" && d.Missing != MissingDrop {
+		return 0
 	}
-	var pval float64
 
-	if d.Method != "asymptotic" && d.Method != "permutation" {
-		return 0, 0, errors.New("xicor: invalid p-value calculation method; use either 'asymptotic' or 'permutation'")
+	var dropped int
+	for i := 0; i < len(d.X) && i < len(d.Y); i++ {
+		if math.IsNaN(d.X[i]) || math.IsNaN(d.Y[i]) {
+			dropped++
+		}
 	}
-	if !d.WantPvalue {
-		return 0, 0, errors.New("xicor: trying to calculate the p-value on an object where `Xi.WantPvalues=false`")
+	return dropped
+}
+
+// clean validates the input vectors and applies the missing-value policy of `d`, returning the data vectors to be used in the calculation.
+func clean[X, Y cmp.Ordered](d *Xi, x []X, y []Y) ([]X, []Y, error) {
+	if len(x) != len(y) {
+		return nil, nil, errors.New("xicor: mismatched size of input vectors")
 	}
 
-	// If there are no data ties, we can use some simpler theory to calculate the theoretical P-value
-	if d.DataTies == false {
-		pval = 1 - pnorm(math.Sqrt(d.n)*xi/math.Sqrt(2./5.))
-		return xi, pval, nil
+	switch d.Missing {
+	case "", MissingDrop:
+		x, y := removeNaNs(x, y)
+		return x, y, nil
+	case MissingError:
+		if hasNaN(x) || hasNaN(y) {
+			return nil, nil, errors.New("xicor: input vectors contain NaN values")
+		}
+		return x, y, nil
+	case MissingLast:
+		// The ranking functions already treat NaNs as tied values which are larger than any other
+		return x, y, nil
+	default:
+		return nil, nil, errors.New("xicor: invalid missing-value policy; use either 'drop', 'error' or 'last'")
 	}
+}
 
-	// If there are ties in the input data, the algorithm employs the more elaborated theory for calculating the P-value
-	// There is no harm in setting DataTies to true and using the fancy P-value calculation even if there are no ties
-	if d.Method == "asymptotic" {
-		q := make([]float64, len(d.f))
-		copy(q, d.f)
-		sort.Float64s(q)
+// Result holds the outcome of a single calculation.
+type Result struct {
+	// Xi is the correlation coefficient.
+	Xi float64
+	// Pvalue is the p-value of the independence test, and LogPvalue its natural logarithm, which stays accurate when the p-value is too small to be represented.
+	Pvalue, LogPvalue float64
+	// SD is the standard deviation of xi under independence; for the permutation test it is the standard deviation of the permuted coefficients.
+	SD float64
+	// Statistic is the standardized test statistic `Xi/SD`.
+	Statistic float64
+	// ConfidenceInterval holds the lower and upper bounds of the confidence interval for xi, when one is requested.
+	ConfidenceInterval [2]float64
+	// StdErr is the standard error of xi, which unlike SD doesn't assume independence; it is only calculated for Wald intervals.
+	StdErr float64
+
+	// N is the number of pairs used in the calculation and Dropped the number of pairs removed by the missing-value policy.
+	N, Dropped int
+	// Neighbours is the number of right neighbours each point is compared to; it is 1 unless the revised coefficient is used.
+	Neighbours int
+	// TiesX and TiesY are the number of groups of tied values in X and Y.
+	TiesX, TiesY int
+
+	// Method is the method used to calculate the p-value, and Nperms the number of permutations drawn by the permutation test.
+	// Only the fields above Method are populated when the p-value isn't calculated.
+	Method string
+	Nperms int
+	// MCStdErr is the Monte Carlo standard error of the p-value of the permutation test.
+	MCStdErr float64
+}
 
-		ind := make([]float64, int(d.n))
-		ind2 := make([]float64, int(d.n))
+// Compute calculates the correlation coefficient for x and y, along with its p-value unless `WantPvalue=false`, configured by the same functional options as `New`.
+// It holds no state between calls and is safe for concurrent use, as long as a source supplied via `WithRand` isn't shared between goroutines.
+func Compute(x, y []float64, options ...func(*Xi)) (Result, error) {
+	return ComputeContext(context.Background(), x, y, options...)
+}
 
-		for i := 0; i < int(d.n); i++ {
-			ind[i] = float64(i) + 1.
-			ind2[i] = 2*d.n - 2*ind[i] + 1
-		}
+// ComputeContext is like `Compute`, but stops early and returns the context's error once `ctx` is done.
+func ComputeContext(ctx context.Context, x, y []float64, options ...func(*Xi)) (Result, error) {
+	return ComputeOrderedContext(ctx, x, y, options...)
+}
 
-		asl := make([]float64, int(d.n))
-		csl := make([]float64, int(d.n))
+// ComputeOrdered is the generic counterpart of `Compute`, which accepts any ordered type for x and y, such as integers, strings or `time.Duration`.
+// As xi only depends on the ranks of the data, the values are ranked natively without being converted to float64; for floating-point types NaN is treated as a missing value.
+// The `X` and `Y` fields of the configuration are ignored.
+func ComputeOrdered[X, Y cmp.Ordered](x []X, y []Y, options ...func(*Xi)) (Result, error) {
+	return ComputeOrderedContext(context.Background(), x, y, options...)
+}
 
-		for i := 0; i < int(d.n); i++ {
-			asl[i] = ind2[i] * q[i] * q[i]
-			csl[i] = ind2[i] * q[i]
-		}
-		a := mean(asl) / d.n
-		c := mean(csl) / d.n
-		cq := cumsum(q)
-
-		m := make([]float64, int(d.n))
-		msq := make([]float64, int(d.n))
-		for i := 0; i < int(d.n); i++ {
-			m[i] = (cq[i] + (d.n-ind[i])*q[i]) / d.n
-			msq[i] = m[i] * m[i]
-		}
-		b := mean(msq)
+// ComputeOrderedContext is like `ComputeOrdered`, but stops early and returns the context's error once `ctx` is done.
+func ComputeOrderedContext[X, Y cmp.Ordered](ctx context.Context, x []X, y []Y, options ...func(*Xi)) (Result, error) {
+	d := New(nil, nil, options...)
+	return compute(ctx, d, x, y, d.WantPvalue, d.Interval != "")
+}
 
-		v := (a - 2*b + c*c) / (d.cval * d.cval)
+// Correlation calculates and returns the correlation coefficient for the input data vectors `X` and `Y` along with an error.
+func (d *Xi) Correlation() (float64, error) {
+	return d.CorrelationContext(context.Background())
+}
 
-		pval = 1 - pnorm(math.Sqrt(d.n)*xi/math.Sqrt(v))
-		return xi, pval, nil
+// CorrelationContext is like `Correlation`, but stops early and returns the context's error once `ctx` is done.
+func (d *Xi) CorrelationContext(ctx context.Context) (float64, error) {
+	res, err := compute(ctx, d, d.X, d.Y, false, false)
+	if err != nil {
+		return 0, err
 	}
+	return res.Xi, nil
+}
 
-	// If permutation test is to be used for calculating P-value:
-	if d.Method == "permutation" {
-		r := make([]float64, d.Nperms)
-		for i := 0; i < d.Nperms; i++ {
-			x1 := make([]float64, int(d.n))
-			for i := 0; i < int(d.n); i++ {
-				x1[i] = rand.Float64()
-			}
-			xinew, _, _ := New(x1, d.Y, WithAsymptoticPvalue()).Pvalue()
-			r[i] = xinew
+// Pvalue calculates and returns the correlation coefficient and p-value for the input data vectors `X` and `Y` along with an error.
+func (d *Xi) Pvalue() (float64, float64, error) {
+	return d.PvalueContext(context.Background())
+}
+
+// PvalueContext is like `Pvalue`, but stops early and returns the context's error once `ctx` is done; the permutation test checks it between chunks of permutations.
+func (d *Xi) PvalueContext(ctx context.Context) (float64, float64, error) {
+	res, err := compute(ctx, d, d.X, d.Y, true, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	return res.Xi, res.Pvalue, nil
+}
+
+// compute runs a whole calculation on x and y with the configuration in `d`, without modifying it.
+// The p-value and the confidence interval are only calculated when `pvalue` and `interval` are set.
+func compute[X, Y cmp.Ordered](ctx context.Context, d *Xi, xin []X, yin []Y, pvalue, interval bool) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	x, y, err := clean(d, xin, yin)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := d.validate(pvalue); err != nil {
+		return Result{}, err
+	}
+	if interval {
+		if err := d.validateInterval(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	r := d.rng()
+	m := d.neighbours(len(x))
+	if d.Symmetric {
+		sym := symmetricCorrelation(x, y, m, r)
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
 		}
-		ps := make([]float64, d.Nperms)
-		for i := 0; i < d.Nperms; i++ {
-			if r[i] > xi {
-				ps[i] = 1.0
-			} else {
-				ps[i] = 0.0
+		res := Result{
+			Xi:         sym.xi(),
+			N:          len(x),
+			Dropped:    len(xin) - len(x),
+			Neighbours: m,
+			TiesX:      sym.xy.tiesX,
+			TiesY:      sym.xy.tiesY,
+		}
+		seed := d.streamSeed(r)
+		if interval {
+			s, ord := sym.max()
+			if err := confidence(ctx, d, &res, x, y, s, ord, seed); err != nil {
+				return Result{}, err
 			}
 		}
-		pval = mean(ps)
+		if !pvalue {
+			return res, nil
+		}
+
+		if err := d.testSymmetric(ctx, &res, sym, seed); err != nil {
+			return Result{}, err
+		}
+		return res, nil
+	}
+
+	s, ord := correlation(x, y, m, r)
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Xi:         s.xi,
+		N:          len(x),
+		Dropped:    len(xin) - len(x),
+		Neighbours: m,
+		TiesX:      s.tiesX,
+		TiesY:      s.tiesY,
+	}
+	seed := d.streamSeed(r)
+	if interval {
+		if err := confidence(ctx, d, &res, x, y, s, ord, seed); err != nil {
+			return Result{}, err
+		}
+	}
+	if !pvalue {
+		return res, nil
 	}
 
-	return xi, pval, nil
+	if err := d.test(ctx, &res, s, seed); err != nil {
+		return Result{}, err
+	}
+	return res, nil
 }
 
-func removeIdx(a []int, i int) []int {
-	return append(a[:i], a[i+1:]...)
+// validate checks the p-value configuration of `d`, if a p-value is to be calculated.
+func (d *Xi) validate(pvalue bool) error {
+	if !pvalue {
+		return nil
+	}
+	if d.Method != MethodAsymptotic && d.Method != MethodPermutation && d.Method != MethodExact {
+		return errors.New("xicor: invalid p-value calculation method; use either 'asymptotic', 'permutation' or 'exact'")
+	}
+	if d.Alternative != "" && d.Alternative != AlternativeGreater && d.Alternative != AlternativeLess && d.Alternative != AlternativeTwoSided {
+		return errors.New("xicor: invalid alternative hypothesis; use either 'greater', 'less' or 'two-sided'")
+	}
+	if d.Method == MethodPermutation && d.Nperms < 1 {
+		return errors.New("xicor: the permutation test needs at least one permutation")
+	}
+	if d.Method == MethodPermutation && (d.Exceedances < 0 || d.sequential && d.Exceedances == 0) {
+		return errors.New("xicor: the sequential permutation test needs at least one exceedance")
+	}
+	if d.Method == MethodExact && (d.Symmetric || d.Revised && d.Neighbours > 1) {
+		return errors.New("xicor: the exact p-value is only available for the original coefficient")
+	}
+	if d.withNull && d.Null == nil {
+		return errors.New("xicor: the null distribution is nil")
+	}
+	if d.Method == MethodPermutation && d.Null != nil && d.Symmetric {
+		return errors.New("xicor: the null distribution is only available for the original coefficient")
+	}
+	if !d.WantPvalue {
+		return errors.New("xicor: trying to calculate the p-value on an object where `Xi.WantPvalues=false`")
+	}
+	return nil
 }
 
-func removeNaNs(x, y []float64) ([]float64, []float64) {
-	nans := make(map[int]struct{})
-	for i, xv := range x {
-		if math.IsNaN(xv) {
-			nans[i] = struct{}{}
+// validateInterval checks the confidence interval configuration of `d`.
+func (d *Xi) validateInterval() error {
+	if d.Interval != IntervalPercentile && d.Interval != IntervalBasic && d.Interval != IntervalBCa && d.Interval != IntervalWald {
+		return errors.New("xicor: invalid confidence interval method; use either 'percentile', 'basic', 'bca' or 'wald'")
+	}
+	if d.Interval != IntervalWald && d.Nboot < 1 {
+		return errors.New("xicor: the bootstrap needs at least one resample")
+	}
+	if !(d.Level > 0 && d.Level < 1) {
+		return errors.New("xicor: the confidence level must be between 0 and 1")
+	}
+	return nil
+}
+
+// test fills in the p-value of the coefficient `s.xi` in `res`, along with the related fields.
+// It uses the asymptotic variance and permutation distribution cached in `s` when they're available, and never modifies `s`.
+// With `m` right neighbours, the variance of xi under independence shrinks by a further factor of m.
+func (d *Xi) test(ctx context.Context, res *Result, s stats, seed int64) error {
+	n := float64(len(s.f) * s.m)
+	res.Method = d.Method
+
+	// The exact distribution accounts for the ties by itself
+	if d.Method == MethodExact {
+		if len(s.f) > exactLimit {
+			return fmt.Errorf("xicor: the exact p-value is only available for up to %d observations; use either the asymptotic or the permutation method", exactLimit)
+		}
+		// The default number of neighbours of the revised coefficient is only known once the sample size is
+		if s.m > 1 {
+			return errors.New("xicor: the exact p-value is only available for the original coefficient")
 		}
+		res.exact(s, d.Alternative)
+		return nil
 	}
-	for j, yv := range y {
-		if math.IsNaN(yv) {
-			nans[j] = struct{}{}
+
+	// If there are no data ties, we can use some simpler theory to calculate the theoretical P-value
+	if d.DataTies == false {
+		res.Method = MethodAsymptotic
+		res.SD = math.Sqrt(2. / 5. / n)
+		res.Statistic = s.xi / res.SD
+		res.normal(d.Alternative)
+		return nil
+	}
+
+	// If there are ties in the input data, the algorithm employs the more elaborated theory for calculating the P-value
+	// There is no harm in setting DataTies to true and using the fancy P-value calculation even if there are no ties
+	if d.Method == MethodAsymptotic {
+		v := s.v
+		if v == 0 {
+			v = asymptoticVariance(s.f, s.cval)
 		}
+
+		res.SD = math.Sqrt(v / n)
+		res.Statistic = s.xi / res.SD
+		res.normal(d.Alternative)
+		return nil
 	}
 
-	var newX, newY []float64
-	for i := 0; i < len(x); i++ {
-		if _, ok := nans[i]; ok {
-			continue
+	// If permutation test is to be used for calculating P-value:
+	rp := s.rp
+	if rp == nil && d.Null != nil {
+		if !d.Null.matches(s) {
+			return errors.New("xicor: the null distribution was drawn for a Y with other ties or another number of observations")
 		}
-		newX = append(newX, x[i])
-		newY = append(newY, y[i])
+		rp = d.Null.Coefficients
 	}
+	if rp == nil {
+		var err error
+		rp, err = d.permutations(ctx, s, seed, d.stopRule(s.xi, s.tolerance()))
+		if err != nil {
+			return err
+		}
+	}
+	res.permutation(rp, d.Alternative, d.Exceedances, s.tolerance())
 
-	return newX, newY
+	return nil
 }
 
-func rankRND(a []float64) []float64 {
-	ns := make([]float64, len(a))
-	copy(ns, a)
-	sort.Float64s(ns)
-
-	idx := make(map[float64][]int)
+// normal fills in the p-value of the standardized statistic `res.Statistic` under the `alternative` hypothesis, when it follows the standard normal distribution under independence.
+func (res *Result) normal(alternative string) {
+	greater, logGreater := upperTail(res.Statistic)
+	less, logLess := upperTail(-res.Statistic)
+	res.alternative(alternative, greater, logGreater, less, logLess)
+}
 
-	for i, val := range ns {
-		idx[val] = append(idx[val], i+1)
+// alternative fills in the p-value of the `alternative` hypothesis and its logarithm, from the p-values of the one-sided tests for a larger and a smaller xi than under independence.
+func (res *Result) alternative(alternative string, greater, logGreater, less, logLess float64) {
+	switch alternative {
+	case AlternativeLess:
+		res.Pvalue, res.LogPvalue = less, logLess
+	case AlternativeTwoSided:
+		p, logp := greater, logGreater
+		if logLess < logGreater {
+			p, logp = less, logLess
+		}
+		res.Pvalue = math.Min(2*p, 1)
+		res.LogPvalue = math.Min(math.Ln2+logp, 0)
+	default:
+		res.Pvalue, res.LogPvalue = greater, logGreater
 	}
+}
 
-	res := make([]float64, len(a))
-	for i := range res {
-		pool := idx[a[i]]
-		selectedIdx := rand.Intn(len(pool))
-		res[i] = float64(pool[selectedIdx])
-		idx[a[i]] = removeIdx(idx[a[i]], selectedIdx)
-	}
+// stats holds the intermediate quantities of a calculation of xi which are reused for the p-value and the diagnostics.
+type stats struct {
+	xi float64
+	// f[i] is number of j s.t. y[j] <= y[i], divided by n.
+	f []float64
+	// cval is the denominator of xi.
+	cval         float64
+	tiesX, tiesY int
+	// m is the number of right neighbours each point is compared to.
+	m int
+
+	// v and rp are the asymptotic variance and the permutation distribution under independence, when they're shared between calculations with the same y.
+	v  float64
+	rp []float64
+}
 
-	return res
+// correlation calculates the correlation coefficient for the cleaned data vectors x and y, comparing each point to its `m` right neighbours.
+// It also returns the order of the x's, with ties broken at random.
+func correlation[X, Y cmp.Ordered](x []X, y []Y, m int, r *rand.Rand) (stats, []int) {
+	// order of the x's, ties broken at random.
+	ord, tiesX := argsort(x, r)
+
+	s := prepare(y)
+	s.tiesX = tiesX
+	s.m = m
+	s.xi = s.walk(ord)
+	return s, ord
+}
+
+// prepare calculates the quantities of xi which only depend on y.
+func prepare[Y cmp.Ordered](y []Y) stats {
+	// f[i] is number of j s.t. y[j] <= y[i], divided by n.
+	// g[i] is number of j s.t. y[j] >= y[i], divided by n.
+	f, g, tiesY := rankMaxes(y)
+	return fromRanks(f, g, tiesY)
 }
 
-func rankMax(a []float64) []float64 {
-	ns := make([]float64, len(a))
-	copy(ns, a)
-	sort.Float64s(ns)
+// fromRanks calculates the quantities of xi which only depend on y from its max-ranks `f` and `g`, normalizing them in place.
+func fromRanks(f, g []float64, tiesY int) stats {
+	// Sample Size
+	n := float64(len(f))
 
-	idx := make(map[float64][]int)
-	for i, val := range ns {
-		idx[val] = append(idx[val], i+1)
+	for i := range f {
+		f[i] = f[i] / n
+		g[i] = g[i] / n
 	}
 
-	res := make([]float64, len(a))
-	for i := range res {
-		pool := idx[a[i]]
-		res[i] = float64(pool[len(pool)-1])
+	var cval float64
+	for _, val := range g {
+		cval += val * (1 - val)
 	}
+	cval = cval / n
 
-	return res
+	return stats{
+		f:     f,
+		cval:  cval,
+		tiesY: tiesY,
+		m:     1,
+	}
 }
 
-type fsl struct {
-	sort.Float64Slice
-	idx []int
+// walk calculates xi when the x's order the observations as in `ord`, walking f in that order.
+func (s stats) walk(ord []int) float64 {
+	var A1 float64
+	for k := 1; k <= s.m; k++ {
+		for i := 0; i < len(ord)-k; i++ {
+			A1 += abs(s.f[ord[i]] - s.f[ord[i+k]])
+		}
+	}
+	return s.scale(A1, len(ord))
 }
 
-func (s *fsl) Swap(i, j int) {
-	s.Float64Slice.Swap(i, j)
-	s.idx[i], s.idx[j] = s.idx[j], s.idx[i]
+// scale turns the sum `A1` of the distances between the max-ranks of the `n` points and their right neighbours into xi.
+// The sum is averaged over the pairs of neighbours and rescaled to the n-1 pairs of the original coefficient, so that for m=1 this is exactly xi, and for larger m it is the revised coefficient
+// of Lin and Han, with the ties in Y handled as in the original one. Without ties, the two only differ in the last m points, which are compared to the right neighbours they have rather than to themselves.
+func (s stats) scale(A1 float64, n int) float64 {
+	pairs := float64(s.m*n - s.m*(s.m+1)/2)
+	A1 = A1 / (2 * float64(n)) * (float64(n-1) / pairs)
+
+	return 1 - A1/s.cval
 }
 
-// Initial approach from https://stackoverflow.com/a/31141540
-func argsort(a []float64) []int {
+// asymptoticVariance returns the variance of sqrt(n)*xi under independence, given the normalized max-ranks `f` of Y and the denominator `cval` of xi.
+// It runs in linear time after sorting a copy of `f`, accumulating the prefix sums in place of the cumulative sum vector of the R code.
+func asymptoticVariance(f []float64, cval float64) float64 {
+	n := float64(len(f))
+	q := make([]float64, len(f))
+	copy(q, f)
+	sort.Float64s(q)
+
+	var a, b, c, cq float64
+	for i, qi := range q {
+		ind := float64(i + 1)
+		ind2 := 2*n - 2*ind + 1
+		a += ind2 * qi * qi
+		c += ind2 * qi
+
+		cq += qi
+		m := (cq + (n-ind)*qi) / n
+		b += m * m
+	}
+	a = a / (n * n)
+	c = c / (n * n)
+	b = b / n
+
+	return (a - 2*b + c*c) / (cval * cval)
+}
 
-	indexes := make([]int, len(a))
-	for i := range indexes {
-		indexes[i] = i
+func removeNaNs[X, Y cmp.Ordered](x []X, y []Y) ([]X, []Y) {
+	nans := make(map[int]struct{})
+	for i, xv := range x {
+		if isNaN(xv) {
+			nans[i] = struct{}{}
+		}
+	}
+	for j, yv := range y {
+		if isNaN(yv) {
+			nans[j] = struct{}{}
+		}
 	}
 
-	s := &fsl{Float64Slice: a, idx: indexes}
-	sort.Sort(s)
-
-	// Lets identify ties, which we should randomize
-	var currentStreak []int
-	for i := 0; i < len(a)-1; i++ {
-		currentStreak = append(currentStreak, i)
-		if a[i] == a[i+1] {
-			// we're on a streak
+	var newX []X
+	var newY []Y
+	for i := 0; i < len(x); i++ {
+		if _, ok := nans[i]; ok {
 			continue
-		} else {
-			// a streak was broken
-			if len(currentStreak) > 1 {
-				// we need to randomize the indices
-				first := currentStreak[0]
-				last := currentStreak[len(currentStreak)-1]
-				shuffle(indexes[first : last+1])
-			}
-			// reset the streak
-			currentStreak = []int{}
 		}
+		newX = append(newX, x[i])
+		newY = append(newY, y[i])
 	}
 
-	return indexes
+	return newX, newY
 }
 
-func shuffle(a []int) {
-	rand.Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
-	return
+func hasNaN[T cmp.Ordered](a []T) bool {
+	for _, val := range a {
+		if isNaN(val) {
+			return true
+		}
+	}
+	return false
 }
 
 func abs(a float64) float64 {
@@ -379,11 +835,23 @@ func mean(a []float64) float64 {
 	return sum / float64(len(a))
 }
 
+// sd returns the sample standard deviation of `a`, like R's `sd`.
+func sd(a []float64) float64 {
+	m := mean(a)
+	var ss float64
+	for _, val := range a {
+		ss += (val - m) * (val - m)
+	}
+	return math.Sqrt(ss / float64(len(a)-1))
+}
+
 func cumsum(a []float64) []float64 {
 	res := make([]float64, len(a))
 
-	for i := 0; i < len(a); i++ {
-		res[i] = sum(a[:i+1])
+	var acc float64
+	for i, val := range a {
+		acc += val
+		res[i] = acc
 	}
 
 	return res
@@ -397,16 +865,3 @@ func sum(a []float64) float64 {
 
 	return sum
 }
-
-// From https://en.wikipedia.org/wiki/Normal_distribution
-func pnorm(a float64) float64 {
-	var val, sum, res float64
-	sum = a
-	val = a
-	for i := 1; i <= 100; i++ {
-		val = (val * a * a / (2*float64(i) + 1))
-		sum = sum + val
-	}
-	res = 0.5 + (sum/math.Sqrt(2*math.Pi))*math.Exp(-(a*a)/2)
-	return res
-}
@@ -5,6 +5,8 @@ import (
 	"math"
 	"math/rand"
 	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 )
@@ -22,8 +24,6 @@ var anscombesQuartet = map[string][]float64{
 
 // Test correctness of results -- results compared with initial code by Sourav Chatterjee and Python port by `czbiohub`
 func TestXi(t *testing.T) {
-	rand.Seed(21)
-
 	// Asymptotic p-value calculation
 	xi1, pval1, _ := New(
 		anscombesQuartet["x_1"],
@@ -45,6 +45,7 @@ func TestXi(t *testing.T) {
 		anscombesQuartet["x_3"],
 		anscombesQuartet["y_3"],
 		WithAsymptoticPvalue(),
+		WithSeed(121), // x_3 contains ties, which are broken at random
 	).Pvalue()
 	assertEpsilon(t, xi3, 0.38095238095238093)
 	assertEpsilon(t, pval3, 0.04989192742513937)
@@ -53,6 +54,7 @@ func TestXi(t *testing.T) {
 		anscombesQuartet["x_4"],
 		anscombesQuartet["y_4"],
 		WithAsymptoticPvalue(),
+		WithSeed(121), // x_4 contains ties, which are broken at random
 	).Pvalue()
 	assertEpsilon(t, xi4, 0.19999999999999996)
 	assertEpsilon(t, pval4, 0.1515801165640982)
@@ -62,6 +64,7 @@ func TestXi(t *testing.T) {
 		anscombesQuartet["x_1"],
 		anscombesQuartet["y_1"],
 		WithPermutationPvalue(1000),
+		WithSeed(21),
 	).Pvalue()
 	assertEpsilon(t, xi1, 0.2749999999999999)
 
@@ -95,13 +98,14 @@ func TestXi(t *testing.T) {
 		WithPermutationPvalue(1000),
 	).Pvalue()
 	assertEpsilon(t, xi1, 0.7272727)
-	assertEpsilon(t, pval1, 0)
+	assertEpsilon(t, pval1, 1./1001.) // no permutation is as large, but the observed order counts as one
 
-	wantPval = 0.051425
+	wantPval = 0.0627146 // exact p-value
 	xi1, pval1, _ = New(
 		anscombesQuartet["x_1"],
 		anscombesQuartet["y_1"],
 		WithPermutationPvalue(200_000),
+		WithSeed(21),
 	).Pvalue()
 	assertEpsilon(t, xi1, 0.275)
 	if abs(pval1-wantPval) > 0.01 {
@@ -114,6 +118,294 @@ func TestXi(t *testing.T) {
 	assertEpsilon(t, pval1, 0.4733904) // R value 0.4733904
 }
 
+func TestXiSeed(t *testing.T) {
+	options := []func(*Xi){WithPermutationPvalue(200), WithSeed(42)}
+	xi1, pval1, err := New(anscombesQuartet["x_4"], anscombesQuartet["y_4"], options...).Pvalue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	xi2, pval2, err := New(anscombesQuartet["x_4"], anscombesQuartet["y_4"], options...).Pvalue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xi1 != xi2 || pval1 != pval2 {
+		t.Errorf("identically seeded computations differ; got: (%v, %v) and (%v, %v)", xi1, pval1, xi2, pval2)
+	}
+
+	// Repeated calculations on the same object must also be reproducible
+	xi := New(anscombesQuartet["x_3"], anscombesQuartet["y_3"], WithSeed(7))
+	c1, _ := xi.Correlation()
+	c2, _ := xi.Correlation()
+	if c1 != c2 {
+		t.Errorf("repeated seeded calculations differ; got: %v and %v", c1, c2)
+	}
+
+	// WithRand routes the draws through the supplied source
+	c1, _ = New(anscombesQuartet["x_4"], anscombesQuartet["y_4"], WithRand(rand.New(rand.NewSource(11)))).Correlation()
+	c2, _ = New(anscombesQuartet["x_4"], anscombesQuartet["y_4"], WithRand(rand.New(rand.NewSource(11)))).Correlation()
+	if c1 != c2 {
+		t.Errorf("computations using identically seeded sources differ; got: %v and %v", c1, c2)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	res, err := Compute(anscombesQuartet["x_2"], anscombesQuartet["y_2"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEpsilon(t, res.Xi, 0.6)
+	assertEpsilon(t, res.Pvalue, 0.0010040217037570187)
+
+	res, err = Compute(anscombesQuartet["x_2"], anscombesQuartet["y_2"], func(d *Xi) { d.WantPvalue = false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Pvalue != 0 {
+		t.Errorf("expected no p-value when WantPvalue=false, got %v", res.Pvalue)
+	}
+
+	// A single Xi can be shared between goroutines
+	xi := New(xx, yy, WithSeed(5))
+	want, _, _ := xi.Pvalue()
+	var wg sync.WaitGroup
+	got := make([]float64, 8)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], _, _ = xi.Pvalue()
+		}(i)
+	}
+	wg.Wait()
+	for _, g := range got {
+		if g != want {
+			t.Errorf("concurrent calculations on a shared Xi differ; got: %v, want: %v", g, want)
+		}
+	}
+}
+
+func TestComputeOrdered(t *testing.T) {
+	want, err := Compute(anscombesQuartet["x_4"], anscombesQuartet["y_4"], WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := make([]int64, len(anscombesQuartet["x_4"]))
+	y := make([]float32, len(anscombesQuartet["y_4"]))
+	d := make([]time.Duration, len(anscombesQuartet["x_4"]))
+	for i := range x {
+		x[i] = int64(anscombesQuartet["x_4"][i])
+		y[i] = float32(anscombesQuartet["y_4"][i])
+		d[i] = time.Duration(x[i]) * time.Second
+	}
+	got, err := ComputeOrdered(x, y, WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("wrong result for integer and float32 inputs; got: %+v, want: %+v", got, want)
+	}
+	got, _ = ComputeOrdered(d, y, WithSeed(3))
+	if got != want {
+		t.Errorf("wrong result for duration inputs; got: %+v, want: %+v", got, want)
+	}
+
+	// Strings are ranked lexicographically
+	labels := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	got, err = ComputeOrdered(labels, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEpsilon(t, got.Xi, 0.7272727)
+
+	// NaN is only a missing value for floating-point types
+	y[0] = float32(math.NaN())
+	got, _ = ComputeOrdered(x, y)
+	if got.N != len(x)-1 || got.Dropped != 1 {
+		t.Errorf("wrong sample size for float32 input with a NaN; got: N=%v Dropped=%v", got.N, got.Dropped)
+	}
+}
+
+func TestResult(t *testing.T) {
+	x := append([]float64{math.NaN()}, anscombesQuartet["x_3"]...)
+	y := append([]float64{1}, anscombesQuartet["y_3"]...)
+
+	res, err := Compute(x, y, WithSeed(121))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEpsilon(t, res.Xi, 0.38095238095238093)
+	assertEpsilon(t, res.Pvalue, 0.04989192742513937)
+	assertEpsilon(t, res.Statistic, res.Xi/res.SD)
+	assertEpsilon(t, res.Pvalue, 1-pnorm(res.Statistic))
+	if res.N != 8 || res.Dropped != 1 {
+		t.Errorf("wrong sample size in result; got: N=%v Dropped=%v, want: N=8 Dropped=1", res.N, res.Dropped)
+	}
+	if res.TiesX != 2 || res.TiesY != 0 {
+		t.Errorf("wrong number of tie groups in result; got: X=%v Y=%v, want: X=2 Y=0", res.TiesX, res.TiesY)
+	}
+	if res.Method != MethodAsymptotic || res.Nperms != 0 {
+		t.Errorf("wrong method in result; got: %v with %v permutations", res.Method, res.Nperms)
+	}
+
+	res, err = Compute(xx, yy, WithoutTies())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEpsilon(t, res.SD, math.Sqrt(2./5./1000.))
+
+	res, err = Compute(xx, yy, WithPermutationPvalue(100), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Method != MethodPermutation || res.Nperms != 100 {
+		t.Errorf("wrong method in result; got: %v with %v permutations", res.Method, res.Nperms)
+	}
+	// The permutation distribution should be close to the asymptotic one
+	if res.SD < 0.02 || res.SD > 0.03 {
+		t.Errorf("unexpected standard deviation of the permutation distribution: %v", res.SD)
+	}
+}
+
+func TestNeighbours(t *testing.T) {
+	d := New(nil, nil, WithRevised(0))
+	// The default is the floor of the fifth root, including at the exact powers where the floating-point root falls short
+	for n, want := range map[int]int{2: 1, 31: 1, 32: 2, 242: 2, 243: 3, 32767: 7, 32768: 8, 100_000: 10, 1_048_576: 16} {
+		if got := d.neighbours(n); got != want {
+			t.Errorf("wrong default number of neighbours for n=%v; got: %v, want: %v", n, got, want)
+		}
+	}
+	for k := 2; k <= 60; k++ {
+		n := k * k * k * k * k
+		if d.neighbours(n) != k || d.neighbours(n-1) != k-1 {
+			t.Errorf("wrong default number of neighbours around n=%v^5; got: %v and %v", k, d.neighbours(n), d.neighbours(n-1))
+		}
+	}
+
+	// An explicit number is capped by the sample size
+	if got := New(nil, nil, WithRevised(5)).neighbours(4); got != 3 {
+		t.Errorf("expected the neighbours to be capped at n-1, got: %v", got)
+	}
+}
+
+func TestRevised(t *testing.T) {
+	// With a single neighbour, the revised coefficient is the original one
+	want, _ := Compute(xx, yy, WithSeed(1))
+	got, err := Compute(xx, yy, WithSeed(1), WithRevised(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Xi != want.Xi || got.Pvalue != want.Pvalue || got.Neighbours != 1 {
+		t.Errorf("wrong result for a single neighbour; got: %+v, want: %+v", got, want)
+	}
+
+	// The default number of neighbours grows as n^(1/5)
+	got, _ = Compute(xx, yy, WithSeed(1), WithRevised(0))
+	if got.Neighbours != 3 {
+		t.Errorf("wrong default number of neighbours; got: %v, want: 3", got.Neighbours)
+	}
+
+	// Without ties, compare against the formula of Lin and Han, in which the last points are compared to themselves; the two agree up to terms of order m*m/n
+	r := rand.New(rand.NewSource(2))
+	n, m := 5000, 5
+	x := make([]float64, n)
+	y := make([]float64, n)
+	for i := range x {
+		x[i] = r.NormFloat64()
+		y[i] = math.Sin(3*x[i]) + r.NormFloat64()
+	}
+	ord := make([]int, n)
+	for i := range ord {
+		ord[i] = i
+	}
+	sort.Slice(ord, func(i, j int) bool { return x[ord[i]] < x[ord[j]] })
+	rank := rankMax(y)
+	var sum float64
+	for i := range ord {
+		for k := 1; k <= m; k++ {
+			j := i + k
+			if j >= n {
+				j = i
+			}
+			sum += math.Min(rank[ord[i]], rank[ord[j]])
+		}
+	}
+	nf, mf := float64(n), float64(m)
+	linHan := -2 + 6*sum/((nf+1)*(nf*mf+mf*(mf+1)/4))
+	got, _ = Compute(x, y, WithRevised(m))
+	if math.Abs(got.Xi-linHan) > 2e-3 {
+		t.Errorf("revised coefficient doesn't match the formula of Lin and Han; got: %v, want: %v", got.Xi, linHan)
+	}
+	assertEpsilon(t, got.SD, math.Sqrt(2./5./nf/mf))
+
+	// Comparing to more neighbours gives more power against a smooth alternative
+	orig, _ := Compute(x, y, WithSeed(1))
+	if got.Statistic <= orig.Statistic {
+		t.Errorf("expected a larger test statistic for the revised coefficient; got: %v, original: %v", got.Statistic, orig.Statistic)
+	}
+
+	// Under independence, the asymptotic standard deviation should match the permutation one, with ties or without
+	for i := range y {
+		y[i] = math.Round(r.NormFloat64())
+	}
+	asym, _ := Compute(x, y, WithRevised(m))
+	perm, _ := Compute(x, y, WithRevised(m), WithPermutationPvalue(500), WithSeed(1))
+	if math.Abs(asym.SD/perm.SD-1) > 0.1 {
+		t.Errorf("asymptotic and permutation standard deviations differ; got: %v and %v", asym.SD, perm.SD)
+	}
+}
+
+func TestAlternative(t *testing.T) {
+	// Y alternates with the order of X, so xi is far below its null distribution
+	x := make([]float64, 40)
+	y := make([]float64, 40)
+	for i := range x {
+		x[i] = float64(i)
+		y[i] = float64(i % 2)
+	}
+
+	for _, method := range []func(*Xi){WithAsymptoticPvalue(), WithPermutationPvalue(500), WithExactPvalue()} {
+		greater, err := Compute(x, y, method, WithSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		less, _ := Compute(x, y, method, WithSeed(1), WithAlternative(AlternativeLess))
+		both, _ := Compute(x, y, method, WithSeed(1), WithAlternative(AlternativeTwoSided))
+		if greater.Pvalue < 0.99 || less.Pvalue > 0.01 {
+			t.Errorf("%v: expected xi to be significantly smaller than under independence; got: greater=%v, less=%v", greater.Method, greater.Pvalue, less.Pvalue)
+		}
+		assertEpsilon(t, both.Pvalue, math.Min(2*math.Min(greater.Pvalue, less.Pvalue), 1))
+		assertEpsilon(t, math.Exp(less.LogPvalue), less.Pvalue)
+	}
+
+	// Without atoms in the null distribution the one-sided p-values add up to 1
+	greater, _ := Compute(xx, yy)
+	less, _ := Compute(xx, yy, WithAlternative(AlternativeLess))
+	both, _ := Compute(xx, yy, WithAlternative(AlternativeTwoSided))
+	assertEpsilon(t, greater.Pvalue+less.Pvalue, 1)
+	assertEpsilon(t, both.Pvalue, 2*math.Min(greater.Pvalue, less.Pvalue))
+
+	// The exact distribution counts the observed value in both tails
+	greater, _ = Compute(x[:12], yy[:12], WithExactPvalue(), WithSeed(1))
+	less, _ = Compute(x[:12], yy[:12], WithExactPvalue(), WithSeed(1), WithAlternative(AlternativeLess))
+	if sum := greater.Pvalue + less.Pvalue; sum <= 1 || sum > 1.2 {
+		t.Errorf("unexpected sum of the exact one-sided p-values: %v", sum)
+	}
+
+	// The symmetric coefficient is smaller than under independence only if both directions are
+	symGreater, _ := Compute(x, y, WithSymmetric(), WithSeed(1))
+	symLess, _ := Compute(x, y, WithSymmetric(), WithSeed(1), WithAlternative(AlternativeLess))
+	assertEpsilon(t, symGreater.Pvalue+symLess.Pvalue, 1)
+	if symLess.Pvalue < 0.1 {
+		t.Errorf("expected xi(Y, X) to keep the symmetric coefficient within its null distribution; got: %v", symLess.Pvalue)
+	}
+
+	_, err := Compute(xx, yy, WithAlternative("different"))
+	if err == nil || err.Error() != "xicor: invalid alternative hypothesis; use either 'greater', 'less' or 'two-sided'" {
+		t.Errorf("didn't receive the correct error when providing an invalid alternative: %v", err)
+	}
+}
+
 func TestXiErrors(t *testing.T) {
 	x := []float64{1, 2, 3}
 	y := []float64{5, 6}
@@ -132,10 +424,26 @@ func TestXiErrors(t *testing.T) {
 	xi = New(x, x)
 	xi.Method = "invalid method"
 	_, _, err = xi.Pvalue()
-	if err.Error() != "xicor: invalid p-value calculation method; use either 'asymptotic' or 'permutation'" {
+	if err.Error() != "xicor: invalid p-value calculation method; use either 'asymptotic', 'permutation' or 'exact'" {
 		t.Errorf("didn't receive the correct error when providing an invalid p-value calculation method: %v", err)
 	}
 
+	for _, tc := range []struct {
+		option func(*Xi)
+		err    string
+	}{
+		{WithPermutationPvalue(0), "xicor: the permutation test needs at least one permutation"},
+		{WithPermutationPvalue(-5), "xicor: the permutation test needs at least one permutation"},
+		{WithSequentialPermutationPvalue(10, 0), "xicor: the permutation test needs at least one permutation"},
+		{WithSequentialPermutationPvalue(0, 500), "xicor: the sequential permutation test needs at least one exceedance"},
+		{WithSequentialPermutationPvalue(-1, 500), "xicor: the sequential permutation test needs at least one exceedance"},
+	} {
+		_, err = Compute(x, x, tc.option)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("didn't receive the correct error when asking for no permutations or exceedances: %v", err)
+		}
+	}
+
 	xi.Method = MethodAsymptotic
 	xi.WantPvalue = false
 
@@ -145,6 +453,51 @@ func TestXiErrors(t *testing.T) {
 	}
 }
 
+func TestXiMissing(t *testing.T) {
+	x := []float64{10, 8, math.NaN(), 13, 9, 11, 14, 6, 4, 12, 7, 5}
+	y := []float64{8.04, 6.95, 1, 7.58, 8.81, 8.33, 9.96, 7.24, 4.26, 10.84, 4.82, math.NaN()}
+
+	// Pairs with a NaN are dropped by default
+	xi := New(x, y, WithAsymptoticPvalue())
+	if got := xi.Dropped(); got != 2 {
+		t.Errorf("wrong number of dropped pairs; got: %v, want: %v", got, 2)
+	}
+	gotXi, gotPval, err := xi.Pvalue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cx, cy := removeNaNs(x, y)
+	wantXi, wantPval, _ := New(cx, cy, WithAsymptoticPvalue()).Pvalue()
+	assertEpsilon(t, gotXi, wantXi)
+	assertEpsilon(t, gotPval, wantPval)
+
+	_, err = New(x, y, WithMissing(MissingError)).Correlation()
+	if err == nil || err.Error() != "xicor: input vectors contain NaN values" {
+		t.Errorf("didn't receive the correct error when providing NaNs with MissingError: %v", err)
+	}
+
+	// NaNs in Y are tied at the end, so they're treated like the largest value
+	xi = New(
+		[]float64{1, 2, 3, 4, 5, 6},
+		[]float64{1, 2, 3, 4, math.NaN(), math.NaN()},
+		WithMissing(MissingLast),
+	)
+	if got := xi.Dropped(); got != 0 {
+		t.Errorf("wrong number of dropped pairs; got: %v, want: %v", got, 0)
+	}
+	gotXi, err = xi.Correlation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantXi, _ = New([]float64{1, 2, 3, 4, 5, 6}, []float64{1, 2, 3, 4, 5, 5}).Correlation()
+	assertEpsilon(t, gotXi, wantXi)
+
+	_, err = New(x, y, WithMissing("invalid")).Correlation()
+	if err == nil || err.Error() != "xicor: invalid missing-value policy; use either 'drop', 'error' or 'last'" {
+		t.Errorf("didn't receive the correct error when providing an invalid missing-value policy: %v", err)
+	}
+}
+
 // Test helpers
 
 func TestRemoveNaNs(t *testing.T) {
@@ -169,64 +522,34 @@ func TestRemoveNaNs(t *testing.T) {
 	}
 }
 
-func TestRank_RND_Max(t *testing.T) {
-	rand.Seed(time.Now().Unix())
-	input := []float64{0., 2., 3., 2.}
-
-	want1 := []float64{1., 2., 4., 3.}
-	want2 := []float64{1., 3., 4., 2.}
-
-	got := rankRND(input)
-	if !reflect.DeepEqual(got, want1) &&
-		!reflect.DeepEqual(got, want2) {
-		t.Errorf("wrong result for rankRND with input:%v, got:%v", input, got)
-	}
-
-	got = rankMax(input)
-	want := []float64{1., 3., 4., 3.}
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("wrong result for rankMax with input:%v, got:%v, want:%v", input, got, want)
-	}
-}
-
-func TestArgsort(t *testing.T) {
-	input := []float64{3., 1., 2.}
-
-	got := argsort(input)
-	want := []int{1, 2, 0}
-
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("wrong result for argsort with input:%v, got:%v, want:%v", input, got, want)
-	}
-}
-
-func TestArgsortWithTies(t *testing.T) {
-	rand.Seed(time.Now().Unix())
-	input := []float64{5, 10, 2, 99, 5, 2, 8, 17, 5}
-	got := argsort(input)
-
-	// [2 5 0 8 4 6 1 7 3]
-	// [5 2 0 8 4 6 1 7 3]
-	// [2 5 4 0 8 6 1 7 3]
-	// [2 5 0 8 4 6 1 7 3]
-	// [2 5 4 8 0 6 1 7 3]
-	// [5 2 8 0 4 6 1 7 3]
-	// ties (i.e. indexes {2, 5} and {0, 4, 8}) should be shuffled
-
-	tie1 := got[0:2]
-	tie2 := got[2:5]
-	rest := got[5:]
-	if !(contains(tie1, 2) && contains(tie1, 5)) {
-		t.Error("first tie block should contain 2 and 5")
-	}
-
-	if !(contains(tie2, 0) && contains(tie2, 4) && contains(tie2, 8)) {
-		t.Error("second tie should contain 0, 4 and 8")
-	}
-
-	wantRest := []int{6, 1, 7, 3}
-	if !reflect.DeepEqual(rest, wantRest) {
-		t.Error("rest of the argsort result should be [1 7 3]")
+func TestAsymptoticVariance(t *testing.T) {
+	f, g, _ := rankMaxes(yy)
+	n := float64(len(f))
+	var cval float64
+	for i := range f {
+		f[i] /= n
+		g[i] /= n
+		cval += g[i] * (1 - g[i]) / n
+	}
+
+	// Direct transcription of the R code
+	q := make([]float64, len(f))
+	copy(q, f)
+	sort.Float64s(q)
+	cq := cumsum(q)
+	var a, b, c float64
+	for i := range q {
+		ind := float64(i + 1)
+		a += (2*n - 2*ind + 1) * q[i] * q[i] / (n * n)
+		c += (2*n - 2*ind + 1) * q[i] / (n * n)
+		m := (cq[i] + (n-ind)*q[i]) / n
+		b += m * m / n
+	}
+	want := (a - 2*b + c*c) / (cval * cval)
+
+	got := asymptoticVariance(f, cval)
+	if math.Abs(got-want) > 1e-12 {
+		t.Errorf("wrong result for asymptoticVariance, got:%v, want:%v", got, want)
 	}
 }
 
@@ -282,6 +605,15 @@ func TestMean(t *testing.T) {
 	}
 }
 
+func TestSD(t *testing.T) {
+	input := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	got := sd(input)
+	want := math.Sqrt(32. / 7.)
+	if got != want {
+		t.Errorf("wrong result for sd with input: %v, got:%v, want:%v", input, got, want)
+	}
+}
+
 func TestCumsum(t *testing.T) {
 	input := []float64{}
 	got := cumsum(input)
@@ -360,6 +692,28 @@ func BenchmarkPvalues(b *testing.B) {
 	pvalue = p
 }
 
+func BenchmarkPvalueScaling(b *testing.B) {
+	for _, n := range []int{1_000, 10_000, 100_000, 500_000} {
+		x := make([]float64, n)
+		y := make([]float64, n)
+		for i := range x {
+			x[i] = rand.NormFloat64()
+			y[i] = float64(rand.Intn(100))
+		}
+
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			var c, p float64
+			xi := New(x, y)
+			for i := 0; i < b.N; i++ {
+				c, p, _ = xi.Pvalue()
+			}
+			corr = c
+			pvalue = p
+		})
+	}
+}
+
 // Test helpers
 
 func contains(s []int, e int) bool {